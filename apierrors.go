@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ProviderError is an error response from a provider's HTTP API
+type ProviderError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *ProviderError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("status %d: %s: %s", e.StatusCode, e.Type, e.Message)
+	}
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+}
+
+// statusCode returns the HTTP status code carried by a provider error, or 0
+func statusCode(err error) int {
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	var providerErr *ProviderError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		return reqErr.HTTPStatusCode
+	case errors.As(err, &providerErr):
+		return providerErr.StatusCode
+	}
+	return 0
+}
+
+// describeError turns a provider error into a short message for the UI
+func describeError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return describeStatus(apiErr.HTTPStatusCode, apiErr.Message)
+	}
+
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return describeStatus(reqErr.HTTPStatusCode, reqErr.Error())
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return describeStatus(providerErr.StatusCode, providerErr.Message)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Sprintf("network error: %v", err)
+	}
+
+	return err.Error()
+}
+
+// describeStatus prefixes a provider error message with what the status code means
+func describeStatus(status int, message string) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return "authentication failed (check api_key): " + message
+	case status == http.StatusNotFound:
+		return "model or endpoint not found: " + message
+	case status == http.StatusTooManyRequests:
+		return "rate limited: " + message
+	case status >= 500:
+		return fmt.Sprintf("provider error (%d): %s", status, message)
+	case status > 0:
+		return fmt.Sprintf("request failed (%d): %s", status, message)
+	}
+	return message
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	// Largest text file that can be attached
+	maxAttachFileSize = 512 * 1024
+	// Most files a single /attach may add
+	maxAttachFiles = 200
+	// Attachments estimated above this many tokens trigger a warning
+	largeAttachmentTokens = 8000
+	// Most attachments listed above the input box
+	maxListedAttachments = 6
+)
+
+// attachment is a file or image sent as an extra part of the next user message
+type attachment struct {
+	name   string
+	tokens int
+	part   openai.ChatMessagePart
+}
+
+// Files and images attached with /attach or /image that go out with the next message
+var pendingAttachments []attachment
+
+// attachCommand handles /attach, inlining text files, globs and directories into
+// the next message
+func attachCommand(g *gocui.Gui, args []string) error {
+	if len(args) == 0 {
+		setStatus(g, "Usage: "+commands["attach"].usage)
+		return nil
+	}
+	if len(args) == 1 && args[0] == "clear" {
+		return clearAttachments(g)
+	}
+
+	var paths []string
+	for _, arg := range args {
+		found, err := expandAttachPath(unquotePath(arg))
+		if err != nil {
+			setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+			return nil
+		}
+		paths = append(paths, found...)
+	}
+	if len(paths) > maxAttachFiles {
+		setStatus(g, fmt.Sprintf("\033[31mToo many files (%d, at most %d)\033[0m", len(paths), maxAttachFiles))
+		return nil
+	}
+
+	added, skipped := 0, 0
+	for _, path := range paths {
+		file, err := loadTextFile(path)
+		if err != nil {
+			skipped++
+			continue
+		}
+		pendingAttachments = append(pendingAttachments, file)
+		added++
+	}
+
+	status := fmt.Sprintf("Attached %d file(s)", added)
+	if skipped > 0 {
+		status += fmt.Sprintf(", skipped %d binary, large or unreadable file(s)", skipped)
+	}
+	if tokens := attachmentTokens(pendingAttachments); tokens > attachmentWarningTokens() {
+		status = fmt.Sprintf("\033[33m%s; attachments are about %d tokens, which may not fit the context window\033[0m", status, tokens)
+	}
+	setStatus(g, status)
+	return nil
+}
+
+// clearAttachments drops the attachments waiting to be sent
+func clearAttachments(g *gocui.Gui) error {
+	pendingAttachments = nil
+	setStatus(g, "Removed the attachments")
+	return nil
+}
+
+// expandAttachPath turns an /attach argument into file paths: a glob is expanded,
+// a directory is walked while honouring its .gitignore files
+func expandAttachPath(pattern string) ([]string, error) {
+	matches := []string{pattern}
+	if strings.ContainsAny(pattern, "*?[") {
+		var err error
+		matches, err = filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", pattern)
+		}
+	}
+
+	var paths []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", match, err)
+		}
+		if !info.IsDir() {
+			paths = append(paths, match)
+			continue
+		}
+
+		files, err := walkAttachDir(match)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, files...)
+	}
+	return paths, nil
+}
+
+// walkAttachDir lists the files under a directory, skipping .git and anything
+// its .gitignore files exclude
+func walkAttachDir(root string) ([]string, error) {
+	ignore := &gitignore{}
+	var files []string
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+
+		if entry.IsDir() {
+			if entry.Name() == ".git" || (rel != "." && ignore.ignored(rel, true)) {
+				return filepath.SkipDir
+			}
+			ignore.load(path, rel)
+			return nil
+		}
+		if !ignore.ignored(rel, false) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", root, err)
+	}
+	return files, nil
+}
+
+// loadTextFile reads a text file into a fenced block with a filename header
+func loadTextFile(path string) (attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return attachment{}, err
+	}
+	if info.Size() > maxAttachFileSize {
+		return attachment{}, fmt.Errorf("%s is larger than %d KB", path, maxAttachFileSize/1024)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return attachment{}, err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return attachment{}, fmt.Errorf("%s is not a text file", path)
+	}
+
+	// Use a fence longer than any backtick run in the file so it cannot end early
+	fence := "```"
+	for strings.Contains(string(data), fence) {
+		fence += "`"
+	}
+	language := strings.TrimPrefix(filepath.Ext(path), ".")
+	text := fmt.Sprintf("File: %s\n%s%s\n%s\n%s", path, fence, language, strings.TrimRight(string(data), "\n"), fence)
+
+	return attachment{
+		name:   path,
+		tokens: estimateTokens(text),
+		part:   openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: text},
+	}, nil
+}
+
+// attachmentTokens returns the estimated token count of the attachments
+func attachmentTokens(attachments []attachment) int {
+	tokens := 0
+	for _, a := range attachments {
+		tokens += a.tokens
+	}
+	return tokens
+}
+
+// attachmentWarningTokens is the attachment size that is worth a warning for the active model
+func attachmentWarningTokens() int {
+	if window := models[activeModel].ContextWindow; window > 0 {
+		return min(largeAttachmentTokens, window/2)
+	}
+	return largeAttachmentTokens
+}
+
+// userMessage builds a user message from the typed text and attachments. Messages
+// with attachments are multi-part: the typed text comes first, followed by one part
+// per attachment, whose names are kept in the metadata for display.
+func userMessage(text string, attachments []attachment) (openai.ChatCompletionMessage, MessageMeta) {
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser}
+	if len(attachments) == 0 {
+		msg.Content = text
+		return msg, MessageMeta{}
+	}
+
+	var meta MessageMeta
+	msg.MultiContent = []openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: text}}
+	for _, a := range attachments {
+		msg.MultiContent = append(msg.MultiContent, a.part)
+		meta.Attachments = append(meta.Attachments, a.name)
+	}
+	return msg, meta
+}
+
+// messageAttachments returns the attachments of a user message built by userMessage
+func messageAttachments(msg openai.ChatCompletionMessage, meta MessageMeta) []attachment {
+	var attachments []attachment
+	for i, name := range meta.Attachments {
+		if i+1 >= len(msg.MultiContent) {
+			break
+		}
+		part := msg.MultiContent[i+1]
+		tokens := estimateTokens(part.Text)
+		if part.Type == openai.ChatMessagePartTypeImageURL {
+			tokens = imageTokenEstimate
+		}
+		attachments = append(attachments, attachment{name: name, tokens: tokens, part: part})
+	}
+	return attachments
+}
+
+// messageText returns the text of a message, joining the text parts of multi-part messages
+func messageText(msg openai.ChatCompletionMessage) string {
+	if len(msg.MultiContent) == 0 {
+		return msg.Content
+	}
+
+	var parts []string
+	for _, part := range msg.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText {
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// messageInput returns the text the user typed for a message, leaving out attachments
+func messageInput(msg openai.ChatCompletionMessage, meta MessageMeta) string {
+	if len(meta.Attachments) > 0 && len(msg.MultiContent) > 0 {
+		return msg.MultiContent[0].Text
+	}
+	return messageText(msg)
+}
+
+// printAttachments prints a placeholder line for each attachment of a user message (right-aligned)
+func printAttachments(v *gocui.View, msg openai.ChatCompletionMessage, meta MessageMeta) {
+	width, _ := v.Size()
+	for _, a := range messageAttachments(msg, meta) {
+		line := describeAttachment(a)
+		padding := max(width-len([]rune(line))-2, 0)
+		fmt.Fprintf(v, "%s\033[2m%s\033[0m\n", strings.Repeat(" ", padding), line)
+	}
+}
+
+// describeAttachment gives the placeholder shown for an attachment
+func describeAttachment(a attachment) string {
+	if a.part.Type == openai.ChatMessagePartTypeImageURL {
+		return "[image: " + a.name + "]"
+	}
+	return fmt.Sprintf("[file: %s, ~%d tokens]", a.name, a.tokens)
+}
+
+// layoutAttachments lists the attachments waiting to be sent just above the input box
+func layoutAttachments(g *gocui.Gui, maxX, maxY int) error {
+	if len(pendingAttachments) == 0 {
+		if err := g.DeleteView("attachments"); err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+		return nil
+	}
+
+	lines := min(len(pendingAttachments), maxListedAttachments+1)
+	v, err := g.SetView("attachments", maxX/4, maxY-11-lines, maxX-1, maxY-10)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	v.Title = fmt.Sprintf("Attachments (~%d tokens, /attach clear to remove)", attachmentTokens(pendingAttachments))
+	v.Clear()
+
+	for i, a := range pendingAttachments {
+		if i == maxListedAttachments && len(pendingAttachments) > maxListedAttachments+1 {
+			fmt.Fprintf(v, "\033[2m...and %d more\033[0m\n", len(pendingAttachments)-i)
+			break
+		}
+		fmt.Fprintln(v, describeAttachment(a))
+	}
+	return nil
+}
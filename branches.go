@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+var showTree = false // whether the conversation tree is shown over the chat log
+
+// Switch the selected message (or the last one) to its next sibling branch
+func nextBranch(g *gocui.Gui, v *gocui.View) error {
+	return switchBranch(g, 1)
+}
+
+// Switch the selected message (or the last one) to its previous sibling branch
+func previousBranch(g *gocui.Gui, v *gocui.View) error {
+	return switchBranch(g, -1)
+}
+
+// switchBranch moves the active branch to a sibling of the selected user message,
+// or of the last message when nothing is selected
+func switchBranch(g *gocui.Gui, step int) error {
+	if streaming {
+		return nil
+	}
+
+	index := selectedMessage
+	if index < 0 {
+		index = len(currentConvo.ChatHistory) - 1
+	}
+	if index < 0 || !currentConvo.SwitchBranch(index, step) {
+		return nil
+	}
+	clearFailedTurn()
+
+	chatLogView, err := g.View("chatLog")
+	if err != nil {
+		return err
+	}
+	renderChatLog(chatLogView)
+
+	return saveCurrentConversation()
+}
+
+// Show or hide the conversation tree
+func toggleTree(g *gocui.Gui, v *gocui.View) error {
+	showTree = !showTree
+	return nil
+}
+
+// layoutTree draws the conversation tree in the top-right corner of the chat log
+func layoutTree(g *gocui.Gui, maxX, maxY int) error {
+	if !showTree {
+		if err := g.DeleteView("tree"); err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+		return nil
+	}
+
+	v, err := g.SetView("tree", maxX-maxX/4, 1, maxX-2, maxY-11)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	v.Title = "Tree (t to close)"
+	v.Clear()
+	renderTree(v)
+	return nil
+}
+
+// renderTree prints the conversation tree as an outline. A chain of single
+// replies stays on one indentation level; each fork indents its branches.
+// Messages on the active branch are highlighted.
+func renderTree(v *gocui.View) {
+	active := map[int]bool{}
+	for _, id := range currentConvo.pathIDs {
+		active[id] = true
+	}
+
+	var walk func(ids []int, indent string)
+	walk = func(ids []int, indent string) {
+		for len(ids) == 1 {
+			printTreeNode(v, currentConvo.node(ids[0]), indent, active)
+			ids = currentConvo.children(ids[0])
+		}
+		for i, id := range ids {
+			branch, rest := "├ ", "│ "
+			if i == len(ids)-1 {
+				branch, rest = "└ ", "  "
+			}
+			printTreeNode(v, currentConvo.node(id), indent+branch, active)
+			walk(currentConvo.children(id), indent+rest)
+		}
+	}
+	walk(currentConvo.children(0), "")
+}
+
+// printTreeNode prints one line of the tree: the role and the start of the message
+func printTreeNode(v *gocui.View, node *MessageNode, prefix string, active map[int]bool) {
+	label := "you"
+	switch node.Message.Role {
+	case openai.ChatMessageRoleSystem:
+		label = "system"
+	case openai.ChatMessageRoleAssistant:
+		label = "ai"
+	case openai.ChatMessageRoleTool:
+		label = "tool"
+	}
+
+	text := truncateLine(messageInput(node.Message, node.Meta), 30)
+
+	color := "2"
+	if active[node.ID] {
+		color = "32"
+	}
+	fmt.Fprintf(v, "%s\033[%sm%s: %s\033[0m\n", prefix, color, label, text)
+}
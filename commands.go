@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+// command is a slash command typed into the input view
+type command struct {
+	usage string
+	run   func(g *gocui.Gui, args []string) error
+}
+
+// commands maps slash command names to their handlers
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"regen": {
+			usage: "/regen [temp=<value>] [model=<name>]",
+			run:   regenerateCommand,
+		},
+		"compare": {
+			usage: "/compare <provider/model> <provider/model>... | off",
+			run:   compareCommand,
+		},
+		"keep": {
+			usage: "/keep <n>",
+			run:   keepCommand,
+		},
+		"attach": {
+			usage: "/attach <path|glob>... | clear",
+			run:   attachCommand,
+		},
+		"image": {
+			usage: "/image <path>... | clear",
+			run:   imageCommand,
+		},
+		"mcp": {
+			usage: "/mcp",
+			run:   mcpCommand,
+		},
+		"params": {
+			usage: "/params [<name>=<value|default>...] | reset",
+			run:   paramsCommand,
+		},
+		"help": {
+			usage: "/help",
+			run:   helpCommand,
+		},
+	}
+}
+
+// runCommand parses and runs a slash command line
+func runCommand(g *gocui.Gui, line string) error {
+	fields := strings.Fields(strings.TrimPrefix(line, "/"))
+	if len(fields) == 0 {
+		return helpCommand(g, nil)
+	}
+
+	cmd, exists := commands[fields[0]]
+	if !exists {
+		setStatus(g, fmt.Sprintf("\033[31mUnknown command /%s\033[0m (try /help)", fields[0]))
+		return nil
+	}
+	return cmd.run(g, fields[1:])
+}
+
+// helpCommand lists the available slash commands in the command bar
+func helpCommand(g *gocui.Gui, args []string) error {
+	usages := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		usages = append(usages, cmd.usage)
+	}
+	sort.Strings(usages)
+	setStatus(g, strings.Join(usages, "  "))
+	return nil
+}
+
+// parseOptions splits key=value command arguments into a map
+func parseOptions(args []string) (map[string]string, error) {
+	options := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, found := strings.Cut(arg, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", arg)
+		}
+		options[key] = value
+	}
+	return options, nil
+}
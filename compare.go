@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// compareTarget is one provider/model pair that compare mode sends input to
+type compareTarget struct {
+	provider string
+	model    ModelConfig
+}
+
+func (t compareTarget) String() string {
+	return t.provider + "/" + t.model.Name
+}
+
+// compareAnswer is one target's streamed answer
+type compareAnswer struct {
+	target compareTarget
+	reply  string
+	meta   MessageMeta
+	err    error
+	done   bool
+}
+
+// comparison is one input sent to every compare target, shown in split panes
+// until an answer is kept or the comparison is dismissed
+type comparison struct {
+	prompt     openai.ChatCompletionMessage
+	promptMeta MessageMeta
+	answers    []*compareAnswer
+}
+
+var (
+	compareTargets   []compareTarget // compare mode is on when this is not empty
+	activeComparison *comparison
+)
+
+// compareCommand handles /compare: with provider/model arguments it turns compare
+// mode on for those pairs, with "off" it turns it off
+func compareCommand(g *gocui.Gui, args []string) error {
+	if len(args) == 0 {
+		if len(compareTargets) == 0 {
+			setStatus(g, "Compare mode is off (usage: "+commands["compare"].usage+")")
+			return nil
+		}
+		setStatus(g, "Comparing "+describeTargets(compareTargets)+" (/compare off to stop)")
+		return nil
+	}
+
+	if len(args) == 1 && args[0] == "off" {
+		compareTargets = nil
+		dismissComparison(g)
+		setStatus(g, "Compare mode off")
+		return nil
+	}
+
+	if len(args) < 2 {
+		setStatus(g, "\033[31mCompare at least two models\033[0m (usage: "+commands["compare"].usage+")")
+		return nil
+	}
+
+	targets := make([]compareTarget, 0, len(args))
+	for _, arg := range args {
+		target, err := parseCompareTarget(arg)
+		if err != nil {
+			setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+			return nil
+		}
+		targets = append(targets, target)
+	}
+
+	compareTargets = targets
+	setStatus(g, "Comparing "+describeTargets(compareTargets)+" (/compare off to stop)")
+	return nil
+}
+
+// keepCommand handles /keep <n>, keeping answer n of the comparison as the reply
+func keepCommand(g *gocui.Gui, args []string) error {
+	if activeComparison == nil {
+		setStatus(g, "Nothing to keep")
+		return nil
+	}
+	if streaming {
+		setStatus(g, "Wait for the answers to finish (Esc to cancel)")
+		return nil
+	}
+
+	number := 0
+	if len(args) == 1 {
+		number, _ = strconv.Atoi(args[0])
+	}
+	if number < 1 || number > len(activeComparison.answers) {
+		setStatus(g, fmt.Sprintf("\033[31mUsage: /keep <1-%d>\033[0m", len(activeComparison.answers)))
+		return nil
+	}
+
+	kept := activeComparison.answers[number-1]
+	if kept.err != nil || kept.reply == "" {
+		setStatus(g, fmt.Sprintf("\033[31m%s has no answer to keep\033[0m", kept.target))
+		return nil
+	}
+
+	// The other answers stay in the conversation tree as sibling branches
+	currentConvo.AddChatMessage(activeComparison.prompt, activeComparison.promptMeta)
+	for _, answer := range activeComparison.answers {
+		if answer == kept || answer.err != nil || answer.reply == "" {
+			continue
+		}
+		currentConvo.AddMessageWithMeta(openai.ChatMessageRoleAssistant, answer.reply, answer.meta)
+		currentConvo.ForkAt(len(currentConvo.ChatHistory) - 1)
+	}
+	currentConvo.AddMessageWithMeta(openai.ChatMessageRoleAssistant, kept.reply, kept.meta)
+
+	activeComparison = nil
+	if chatLogView, err := g.View("chatLog"); err == nil {
+		renderChatLog(chatLogView)
+	}
+	setStatus(g, "Kept the answer from "+kept.target.String())
+
+	return saveCurrentConversation()
+}
+
+// parseCompareTarget reads a provider/model argument. A bare model name refers
+// to the active provider.
+func parseCompareTarget(arg string) (compareTarget, error) {
+	providerName, modelName, found := strings.Cut(arg, "/")
+	if !found {
+		providerName, modelName = providers[activeProvider], arg
+	}
+	if _, err := config.GetProviderConfig(providerName); err != nil {
+		return compareTarget{}, fmt.Errorf("unknown provider %q", providerName)
+	}
+	if modelName == "" {
+		return compareTarget{}, fmt.Errorf("missing model name in %q", arg)
+	}
+
+	model := ModelConfig{Name: modelName}
+	if configured, err := config.GetModelConfig(providerName, modelName); err == nil {
+		model = *configured
+	}
+	return compareTarget{provider: providerName, model: model}, nil
+}
+
+// describeTargets lists compare targets for the command bar
+func describeTargets(targets []compareTarget) string {
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// sendComparison streams answers to the input from every compare target at once.
+// The conversation is only changed once one of the answers is kept.
+func sendComparison(g *gocui.Gui, inputText string) error {
+	clearFailedTurn()
+
+	prompt, promptMeta := userMessage(inputText, pendingAttachments)
+	pendingAttachments = nil
+
+	run := &comparison{prompt: prompt, promptMeta: promptMeta}
+	history := append(withoutReasoning(currentConvo.ChatHistory), prompt)
+
+	summary := currentConvo.Summary
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for _, target := range compareTargets {
+		answer := &compareAnswer{target: target}
+		run.answers = append(run.answers, answer)
+
+		providerConfig, err := config.GetProviderConfig(target.provider)
+		if err != nil {
+			answer.err, answer.done = err, true
+			continue
+		}
+		provider, err := newProvider(target.provider, *providerConfig)
+		if err != nil {
+			answer.err, answer.done = err, true
+			continue
+		}
+
+		// Every target gets the parameters set for this chat, like a normal turn
+		model := currentConvo.Params.apply(target.model)
+		if err := providerConfig.validateSampling(model.Temperature, model.SamplingParams); err != nil {
+			answer.err, answer.done = err, true
+			continue
+		}
+		request := ChatRequest{
+			Model:          model.Name,
+			Temperature:    model.Temperature,
+			Sampling:       model.SamplingParams,
+			Messages:       history,
+			ResponseFormat: model.responseFormat(),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			streamComparisonAnswer(ctx, g, provider, request, summary, answer)
+		}()
+	}
+
+	activeComparison = run
+	cancelRequest = cancel
+	streaming = true
+	setStatus(g, "Comparing "+describeTargets(compareTargets)+"... (Esc to cancel)")
+
+	go func() {
+		wg.Wait()
+		g.Update(func(g *gocui.Gui) error {
+			streaming = false
+			cancelRequest = nil
+			setStatus(g, fmt.Sprintf("Type /keep <1-%d> to keep an answer, Esc to dismiss", len(run.answers)))
+			return nil
+		})
+	}()
+
+	return nil
+}
+
+// streamComparisonAnswer streams one target's answer into its pane
+func streamComparisonAnswer(ctx context.Context, g *gocui.Gui, provider Provider, request ChatRequest, summary *ContextSummary, answer *compareAnswer) {
+	fit := fitContext(ctx, provider, answer.target.model, request.Messages, summary)
+	request.Messages = fit.Messages
+
+	var reply strings.Builder
+	start := time.Now()
+	response, err := streamAttempt(ctx, provider, request, &reply, func(partial string) {
+		g.Update(func(g *gocui.Gui) error {
+			answer.reply = partial
+			return nil
+		})
+	})
+
+	interrupted := err != nil && ctx.Err() != nil
+	if interrupted {
+		err = nil
+	}
+
+	reasoning, final := splitReasoning(reply.String())
+	meta := replyMeta(answer.target.model, request, reply.String(), response.Usage)
+	meta.Reasoning = reasoning
+	meta.Provider = answer.target.provider
+	meta.Model = answer.target.model.Name
+	meta.Interrupted = interrupted
+	meta.StopReason = response.FinishReason
+	meta.LatencyMs = time.Since(start).Milliseconds()
+	if request.ResponseFormat != nil && final != "" && !interrupted {
+		meta.Format = "json"
+		meta.JSONError = checkJSONReply(final, request.ResponseFormat)
+	}
+
+	g.Update(func(g *gocui.Gui) error {
+		answer.reply = final
+		answer.meta = meta
+		answer.err = err
+		answer.done = true
+		if err == nil {
+			recordReplyUsage(answer.target.provider, request.Model, meta)
+		}
+		return nil
+	})
+}
+
+// dismissComparison closes the comparison panes without keeping an answer
+func dismissComparison(g *gocui.Gui) {
+	if cancelRequest != nil && activeComparison != nil {
+		cancelRequest()
+	}
+	activeComparison = nil
+}
+
+// layoutComparison splits the chat log area into one pane per compared answer
+func layoutComparison(g *gocui.Gui, maxX, maxY int) error {
+	count := 0
+	if activeComparison != nil {
+		count = len(activeComparison.answers)
+	}
+
+	left, width := maxX/4, maxX-maxX/4
+	for i := 0; i < count; i++ {
+		x0 := left + width*i/count
+		x1 := left + width*(i+1)/count - 1
+		v, err := g.SetView(comparePaneName(i), x0, 0, x1, maxY-10)
+		if err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+		if err == gocui.ErrUnknownView {
+			v.Wrap = true
+			v.Autoscroll = true
+		}
+		renderCompareAnswer(v, i+1, activeComparison.answers[i])
+	}
+
+	// Remove panes left over from a larger or dismissed comparison
+	for i := count; ; i++ {
+		if err := g.DeleteView(comparePaneName(i)); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func comparePaneName(index int) string {
+	return fmt.Sprintf("compare%d", index)
+}
+
+// renderCompareAnswer draws one answer with its latency and token counts
+func renderCompareAnswer(v *gocui.View, number int, answer *compareAnswer) {
+	v.Title = fmt.Sprintf("[%d] %s", number, answer.target)
+	v.Clear()
+
+	switch {
+	case answer.err != nil:
+		fmt.Fprintf(v, "\033[31mError: %s\033[0m\n", describeError(answer.err))
+		return
+	case answer.reply == "" && !answer.done:
+		fmt.Fprintf(v, "\033[36mtyping...\033[0m\n")
+		return
+	}
+
+	reasoning, reply := splitReasoning(answer.reply)
+	if answer.done {
+		reasoning = answer.meta.Reasoning
+	}
+	if reasoning != "" {
+		fmt.Fprintf(v, "\033[2m[reasoning: %d words]\033[0m\n", len(strings.Fields(reasoning)))
+	}
+	fmt.Fprintln(v, reply)
+	if !answer.done {
+		return
+	}
+
+	fmt.Fprintln(v)
+	meta := answer.meta
+	if meta.Interrupted {
+		fmt.Fprintf(v, "\033[2m[interrupted]\033[0m\n")
+	}
+	usage := formatUsage(meta.PromptTokens, meta.CompletionTokens, meta.Cost)
+	if meta.UsageEstimated {
+		usage = "~" + usage
+	}
+	latency := time.Duration(meta.LatencyMs) * time.Millisecond
+	fmt.Fprintf(v, "\033[2m%s · %s\033[0m\n", usage, latency.Round(100*time.Millisecond))
+}
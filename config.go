@@ -1,43 +1,117 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	openai "github.com/sashabaranov/go-openai"
 	"gopkg.in/yaml.v3"
 )
 
 // ModelConfig represents the configuration for a specific language model
 type ModelConfig struct {
-	Name         string  `yaml:"name"`
-	Temperature  float32 `yaml:"temp"`
-	SystemPrompt string  `yaml:"system_prompt"`
+	Name         string   `yaml:"name"`
+	Temperature  *float32 `yaml:"temp"` // nil leaves it to the provider's default
+	SystemPrompt string   `yaml:"system_prompt"`
+
+	// Optional sampling settings: max_tokens, top_p, stop, seed, presence_penalty,
+	// frequency_penalty and reasoning_effort
+	SamplingParams `yaml:",inline"`
+
+	// Context window in tokens; when set, older turns are trimmed to fit using
+	// context_strategy "truncate" (default) or "summarize"
+	ContextWindow   int    `yaml:"context_window,omitempty"`
+	ContextStrategy string `yaml:"context_strategy,omitempty"`
+
+	// Price in dollars per million input and output tokens, used for cost tracking
+	Pricing *ModelPricing `yaml:"pricing,omitempty"`
+
+	// Structured output: response_format "json_object", or "json_schema" with the
+	// schema read from schema_file (relative to the config file's directory)
+	ResponseFormat string `yaml:"response_format,omitempty"`
+	SchemaFile     string `yaml:"schema_file,omitempty"`
+	// The response format built from the two above when the config is loaded
+	format *openai.ChatCompletionResponseFormat
+
+	// Tools the model may call, e.g. [read_file, list_dir, grep, run_shell]; the file
+	// tools only reach the working directory
+	Tools []string `yaml:"tools,omitempty"`
+	// Offer the tools of the running MCP servers too
+	MCP bool `yaml:"mcp,omitempty"`
+
+	// Ollama-only settings, e.g. options: {num_ctx: 8192} and keep_alive: "10m"
+	Options   map[string]any `yaml:"options,omitempty"`
+	KeepAlive string         `yaml:"keep_alive,omitempty"`
 }
 
 // ProviderConfig represents the configuration for an AI provider
 type ProviderConfig struct {
+	Type     string        `yaml:"type,omitempty"` // provider implementation, defaults to "openai"
 	Endpoint string        `yaml:"endpoint"`
 	APIKey   string        `yaml:"api_key"`
 	Models   []ModelConfig `yaml:"models"`
+
+	// Retries for rate limits and transient server errors (defaults: 3 retries, 30s max delay)
+	MaxRetries    *int          `yaml:"max_retries,omitempty"`
+	MaxRetryDelay time.Duration `yaml:"max_retry_delay,omitempty"`
+
+	// Model discovery through the provider's models endpoint (off unless discover_models: true)
+	DiscoverModels bool          `yaml:"discover_models,omitempty"`
+	ModelsCacheTTL time.Duration `yaml:"models_cache_ttl,omitempty"`
+
+	// Ask for token usage at the end of a stream (defaults to on for the OpenAI API
+	// and off for other endpoints, some of which reject stream_options)
+	StreamUsage *bool `yaml:"stream_usage,omitempty"`
+
+	// Settings for type "mock": canned replies, streaming delay and injected failures
+	Mock *MockConfig `yaml:"mock,omitempty"`
+}
+
+// MCPServerConfig describes a Model Context Protocol server started over stdio
+type MCPServerConfig struct {
+	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+
+	// Disabled servers are not started until they are enabled in the TUI
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Trusted servers' tools run without asking; otherwise only tools the server
+	// marks as read-only do
+	Trusted bool `yaml:"trusted,omitempty"`
 }
 
 // Config represents the root configuration structure with dynamic provider names
 type Config struct {
-	ActiveProvider string
-	ActiveModel    string
-	Providers      map[string]ProviderConfig `yaml:",inline"`
+	ActiveProvider string                     `yaml:"-"`
+	ActiveModel    string                     `yaml:"-"`
+	MCPServers     map[string]MCPServerConfig `yaml:"mcp_servers,omitempty"`
+	Fallback       []string                   `yaml:"fallback,omitempty"` // provider/model pairs tried in order when a request fails
+	Routing        []RouteRule                `yaml:"routing,omitempty"`  // rules that pick the provider/model for each turn
+	Providers      map[string]ProviderConfig  `yaml:",inline"`
+
+	// Problems that disable part of one model instead of stopping atlas, shown at startup
+	warnings []string
 }
 
 // LoadConfig loads the configuration from the default path
 func LoadConfig() (*Config, error) {
-	homeDir, err := os.UserHomeDir()
+	path, err := configPath()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return nil, err
 	}
+	return LoadConfigFromPath(path)
+}
 
-	configPath := filepath.Join(homeDir, ".config", "atlas", "config.yml")
-	return LoadConfigFromPath(configPath)
+// configPath returns the default config file path
+func configPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".config", "atlas", "config.yml"), nil
 }
 
 // LoadConfigFromPath loads the configuration from a specific path
@@ -51,13 +125,41 @@ func LoadConfigFromPath(path string) (*Config, error) {
 	// Initialize the providers map
 	config.Providers = make(map[string]ProviderConfig)
 
-	if err := yaml.Unmarshal(data, &config.Providers); err != nil {
+	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
+	config.resolvePaths(filepath.Dir(path))
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
 
 	return &config, nil
 }
 
+// resolvePaths makes the file paths in the config relative to its directory
+func (c *Config) resolvePaths(dir string) {
+	for _, provider := range c.Providers {
+		if provider.Mock != nil && provider.Mock.Script != "" {
+			provider.Mock.Script = resolveConfigPath(dir, provider.Mock.Script)
+		}
+		for i := range provider.Models {
+			if provider.Models[i].SchemaFile != "" {
+				provider.Models[i].SchemaFile = resolveConfigPath(dir, provider.Models[i].SchemaFile)
+			}
+		}
+	}
+}
+
+// resolveConfigPath expands ~ in a path from the config and makes a relative
+// path relative to the config file's directory
+func resolveConfigPath(dir, path string) string {
+	path = expandHome(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
 // GetAllProviders returns a list of all provider names
 func (c *Config) GetAllProviders() []string {
 	providers := make([]string, 0, len(c.Providers))
@@ -100,3 +202,112 @@ func (c *Config) GetModelsForProvider(provider string) ([]ModelConfig, error) {
 	}
 	return providerConfig.Models, nil
 }
+
+// saveModelParams writes a model's temperature and sampling parameters to its
+// entry in the config file, adding the entry for a discovered model. Comments and
+// the rest of the file are kept.
+func saveModelParams(path, provider string, model ModelConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s is not a mapping", path)
+	}
+
+	providerNode := mappingValue(doc.Content[0], provider)
+	if providerNode == nil || providerNode.Kind != yaml.MappingNode {
+		return fmt.Errorf("provider %s not found in %s", provider, path)
+	}
+	modelsNode := mappingValue(providerNode, "models")
+	if modelsNode == nil {
+		modelsNode = &yaml.Node{Kind: yaml.SequenceNode}
+		setMappingValue(providerNode, "models", modelsNode)
+	}
+
+	var modelNode *yaml.Node
+	for _, entry := range modelsNode.Content {
+		if name := mappingValue(entry, "name"); name != nil && name.Value == model.Name {
+			modelNode = entry
+		}
+	}
+	if modelNode == nil {
+		modelNode = &yaml.Node{Kind: yaml.MappingNode}
+		setMappingValue(modelNode, "name", &yaml.Node{Kind: yaml.ScalarNode, Value: model.Name})
+		modelsNode.Content = append(modelsNode.Content, modelNode)
+	}
+
+	// Encode the parameters with the same field names LoadConfig reads
+	var params yaml.Node
+	err = params.Encode(struct {
+		Temperature    *float32 `yaml:"temp,omitempty"`
+		SamplingParams `yaml:",inline"`
+	}{model.Temperature, model.SamplingParams})
+	if err != nil {
+		return fmt.Errorf("failed to encode parameters: %w", err)
+	}
+	for _, key := range samplingKeys {
+		if value := mappingValue(&params, key); value != nil {
+			setMappingValue(modelNode, key, value)
+		} else {
+			deleteMappingKey(modelNode, key)
+		}
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+	if err := os.WriteFile(path, out.Bytes(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// mappingValue returns the value of a key in a YAML mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets a key in a YAML mapping node, adding it at the end if missing
+func setMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+}
+
+// deleteMappingKey removes a key and its value from a YAML mapping node
+func deleteMappingKey(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigTemperatureDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(path, []byte(`openai:
+  api_key: "test"
+  models:
+    - name: "gpt-4o"
+    - name: "gpt-4o-cold"
+      temp: 0
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfigFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	standIn, provider := newOpenAIStandIn(t, loaded.Providers["openai"], `{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`)
+	merged := mergeModels(loaded.Providers["openai"].Models, []string{"gpt-4o-mini"})
+	if len(merged) != 3 {
+		t.Fatalf("got %d models, want the two configured and one discovered", len(merged))
+	}
+	for _, model := range merged {
+		request := openAITestRequest
+		request.Model = model.Name
+		request.Temperature = model.Temperature
+		streamContent(t, provider, request)
+
+		temperature, sent := standIn.body["temperature"]
+		if model.Name == "gpt-4o-cold" {
+			if !sent {
+				t.Errorf("%s sent no temperature, want temp: 0 sent", model.Name)
+			}
+		} else if sent {
+			t.Errorf("%s sent temperature %v, want the provider default without temp", model.Name, temperature)
+		}
+	}
+}
+
+func TestConfigTemperatureRange(t *testing.T) {
+	for _, test := range []struct {
+		providerType string
+		temp         string
+		valid        bool
+	}{
+		{"openai", "1.5", true},
+		{"openai", "2.5", false},
+		{"anthropic", "1", true},
+		{"anthropic", "1.5", false},
+		{"ollama", "1.5", true},
+		{"openai", "-0.1", false},
+	} {
+		path := filepath.Join(t.TempDir(), "config.yml")
+		err := os.WriteFile(path, []byte(`test:
+  type: "`+test.providerType+`"
+  models:
+    - name: "model"
+      temp: `+test.temp+`
+`), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfigFromPath(path); (err == nil) != test.valid {
+			t.Errorf("%s with temp %s: err = %v, want valid %t", test.providerType, test.temp, err, test.valid)
+		}
+	}
+}
+
+func TestConfigMissingSchemaFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(path, []byte(`openai:
+  models:
+    - name: "gpt-4o"
+      response_format: "json_schema"
+      schema_file: "schemas/missing.json"
+    - name: "gpt-4o-mini"
+      response_format: "json_object"
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfigFromPath(path)
+	if err != nil {
+		t.Fatalf("a missing schema file should not stop loading: %v", err)
+	}
+
+	models := loaded.Providers["openai"].Models
+	if models[0].responseFormat() != nil || models[1].responseFormat() == nil {
+		t.Errorf("formats = %v, %v; want only the model with the missing schema unstructured", models[0].responseFormat(), models[1].responseFormat())
+	}
+	if len(loaded.warnings) != 1 || !strings.Contains(loaded.warnings[0], "gpt-4o") {
+		t.Errorf("warnings = %q, want one about gpt-4o's schema", loaded.warnings)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jroimartin/gocui"
+)
+
+// confirmReply receives the answer to the confirmation prompt on screen, if any
+var confirmReply chan bool
+
+// confirmAction shows a yes/no prompt and waits for the answer. It is called from
+// background goroutines, such as a streaming reply that wants to run a tool.
+func confirmAction(ctx context.Context, g *gocui.Gui, question string) (bool, error) {
+	reply := make(chan bool, 1)
+	updateUI(g, func(g *gocui.Gui) error {
+		confirmReply = reply
+
+		maxX, maxY := g.Size()
+		v, err := g.SetView("confirm", maxX/4, maxY/2-4, maxX*3/4, maxY/2+4)
+		if err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Title = "Confirm (y/n)"
+		v.Wrap = true
+		v.Clear()
+		fmt.Fprintln(v, question)
+
+		g.Cursor = false
+		_, err = setCurrentViewOnTop(g, "confirm")
+		return err
+	})
+
+	select {
+	case answer := <-reply:
+		return answer, nil
+	case <-ctx.Done():
+		updateUI(g, func(g *gocui.Gui) error {
+			confirmReply = nil
+			return closeConfirm(g)
+		})
+		return false, ctx.Err()
+	}
+}
+
+// Accept the action in the confirmation prompt
+func confirmYes(g *gocui.Gui, v *gocui.View) error {
+	return answerConfirm(g, true)
+}
+
+// Decline the action in the confirmation prompt
+func confirmNo(g *gocui.Gui, v *gocui.View) error {
+	return answerConfirm(g, false)
+}
+
+func answerConfirm(g *gocui.Gui, answer bool) error {
+	if confirmReply != nil {
+		confirmReply <- answer
+		confirmReply = nil
+	}
+	return closeConfirm(g)
+}
+
+// closeConfirm removes the confirmation prompt and returns focus to the active view
+func closeConfirm(g *gocui.Gui) error {
+	if err := g.DeleteView("confirm"); err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	if _, err := setCurrentViewOnTop(g, viewArr[active]); err != nil {
+		return err
+	}
+	g.Cursor = active == 4
+	return nil
+}
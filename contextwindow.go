@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	contextStrategyTruncate  = "truncate"
+	contextStrategySummarize = "summarize"
+
+	// Tokens every message costs on top of its content (role, separators)
+	messageTokenOverhead = 4
+	// Upper bound on the tokens kept free for the reply
+	maxReplyReserve = 1024
+	// Upper bound on the tokens a summary of older turns may take
+	maxSummaryTokens = 512
+)
+
+// ContextSummary is a cached summary of the oldest turns of a conversation
+type ContextSummary struct {
+	Text string `json:"text"`
+	// Through is the number of turns after the system prompt that the summary covers
+	Through int `json:"through"`
+}
+
+// contextFit describes how a request's history was trimmed to fit the context window
+type contextFit struct {
+	Messages []openai.ChatCompletionMessage
+	Summary  *ContextSummary
+	Dropped  int
+	Err      error // summarizing failed, so older turns were dropped instead
+}
+
+// estimateTokens gives a rough token count for text (about four characters per token)
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// estimateMessageTokens gives a rough token count for a single message
+func estimateMessageTokens(msg openai.ChatCompletionMessage) int {
+	tokens := messageTokenOverhead + estimateTokens(msg.Content)
+	for _, part := range msg.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeImageURL {
+			tokens += imageTokenEstimate
+			continue
+		}
+		tokens += estimateTokens(part.Text)
+	}
+	for _, call := range msg.ToolCalls {
+		tokens += estimateTokens(call.Function.Name + call.Function.Arguments)
+	}
+	return tokens
+}
+
+// estimateHistoryTokens gives a rough token count for a list of messages
+func estimateHistoryTokens(messages []openai.ChatCompletionMessage) int {
+	tokens := 0
+	for _, msg := range messages {
+		tokens += estimateMessageTokens(msg)
+	}
+	return tokens
+}
+
+// contextBudget returns how many prompt tokens fit in the model's context window
+func (m ModelConfig) contextBudget() int {
+	reserve := min(maxReplyReserve, m.ContextWindow/4)
+	return m.ContextWindow - reserve
+}
+
+// fitContext trims the oldest turns of the history so the request fits the model's
+// context window. The system prompt and the latest message are always kept. With the
+// summarize strategy, dropped turns are replaced by a synthetic summary message,
+// reusing the previous summary where it still applies.
+func fitContext(ctx context.Context, provider Provider, model ModelConfig, messages []openai.ChatCompletionMessage, summary *ContextSummary) contextFit {
+	fit := contextFit{Messages: messages, Summary: summary}
+	if model.ContextWindow <= 0 || estimateHistoryTokens(messages) <= model.contextBudget() {
+		return fit
+	}
+
+	var system []openai.ChatCompletionMessage
+	turns := messages
+	if len(turns) > 0 && turns[0].Role == openai.ChatMessageRoleSystem {
+		system, turns = turns[:1], turns[1:]
+	}
+
+	budget := model.contextBudget() - estimateHistoryTokens(system)
+	summarize := model.ContextStrategy == contextStrategySummarize
+	if summarize {
+		budget -= min(maxSummaryTokens, budget/4)
+	}
+
+	// Drop the oldest turns until the rest fits, starting the kept part on a user turn
+	total := estimateHistoryTokens(turns)
+	drop := 0
+	for drop < len(turns)-1 && (total > budget || turns[drop].Role != openai.ChatMessageRoleUser) {
+		total -= estimateMessageTokens(turns[drop])
+		drop++
+	}
+	fit.Dropped = drop
+
+	kept := append([]openai.ChatCompletionMessage{}, system...)
+	if summarize && drop > 0 {
+		updated, err := summarizeTurns(ctx, provider, model, turns[:drop], summary)
+		if err != nil {
+			fit.Err = err
+		} else {
+			fit.Summary = updated
+			kept = append(kept, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "Summary of the earlier conversation:\n" + updated.Text,
+			})
+		}
+	}
+	fit.Messages = append(kept, turns[drop:]...)
+
+	return fit
+}
+
+// summarizeTurns returns a summary covering the given dropped turns. A previous
+// summary is extended with the turns it does not cover yet instead of starting over.
+func summarizeTurns(ctx context.Context, provider Provider, model ModelConfig, dropped []openai.ChatCompletionMessage, previous *ContextSummary) (*ContextSummary, error) {
+	if previous != nil && previous.Through == len(dropped) {
+		return previous, nil
+	}
+
+	var transcript strings.Builder
+	from := 0
+	if previous != nil && previous.Through < len(dropped) {
+		fmt.Fprintf(&transcript, "Summary so far:\n%s\n\n", previous.Text)
+		from = previous.Through
+	}
+	for _, msg := range dropped[from:] {
+		fmt.Fprintf(&transcript, "%s: %s\n\n", msg.Role, messageText(msg))
+	}
+
+	response, err := provider.Chat(ctx, ChatRequest{
+		Model: model.Name,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role: openai.ChatMessageRoleSystem,
+				Content: fmt.Sprintf("Summarize the following conversation in at most %d words. "+
+					"Keep facts, decisions, names and open questions; leave out pleasantries.", maxSummaryTokens*3/4),
+			},
+			{Role: openai.ChatMessageRoleUser, Content: transcript.String()},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize older messages: %w", err)
+	}
+
+	return &ContextSummary{Text: strings.TrimSpace(response.Content), Through: len(dropped)}, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// summaryStandIn answers summary requests and counts them
+type summaryStandIn struct {
+	requests []ChatRequest
+	err      error
+}
+
+func (s *summaryStandIn) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	s.requests = append(s.requests, request)
+	if s.err != nil {
+		return ChatResponse{}, s.err
+	}
+	return ChatResponse{Content: "summary " + strings.Repeat("I", len(s.requests))}, nil
+}
+
+func (s *summaryStandIn) Stream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *summaryStandIn) ListModels(ctx context.Context) ([]string, error) {
+	return nil, nil
+}
+
+// contextTestHistory returns a system prompt and the given number of alternating
+// user and assistant turns of about 54 tokens each
+func contextTestHistory(turns int) []openai.ChatCompletionMessage {
+	messages := []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: "Be brief."}}
+	for i := range turns {
+		role := openai.ChatMessageRoleUser
+		if i%2 == 1 {
+			role = openai.ChatMessageRoleAssistant
+		}
+		messages = append(messages, openai.ChatCompletionMessage{Role: role, Content: strings.Repeat(string(rune('a'+i))+"bcd ", 50)})
+	}
+	return messages
+}
+
+func TestFitContextFits(t *testing.T) {
+	messages := contextTestHistory(4)
+	for _, model := range []ModelConfig{{}, {ContextWindow: 100000}} {
+		fit := fitContext(context.Background(), nil, model, messages, nil)
+		if fit.Dropped != 0 || len(fit.Messages) != len(messages) {
+			t.Errorf("context window %d: dropped %d messages, want none", model.ContextWindow, fit.Dropped)
+		}
+	}
+}
+
+func TestFitContextTruncate(t *testing.T) {
+	// 400 tokens leave 300 for the prompt: dropping the first user turn is
+	// enough, but the kept part must not start with the assistant's reply to it
+	model := ModelConfig{ContextWindow: 400}
+	messages := contextTestHistory(6)
+	fit := fitContext(context.Background(), nil, model, messages, nil)
+
+	if fit.Dropped != 2 || len(fit.Messages) != len(messages)-2 {
+		t.Fatalf("dropped %d of %d messages, want the first user turn and its reply", fit.Dropped, len(messages))
+	}
+	if fit.Messages[0].Role != openai.ChatMessageRoleSystem || fit.Messages[1].Role != openai.ChatMessageRoleUser {
+		t.Errorf("kept part starts with %s, %s; want the system prompt and a user turn", fit.Messages[0].Role, fit.Messages[1].Role)
+	}
+	if fit.Messages[len(fit.Messages)-1].Content != messages[len(messages)-1].Content {
+		t.Error("the latest message was dropped")
+	}
+	if tokens := estimateHistoryTokens(fit.Messages); tokens > model.contextBudget() {
+		t.Errorf("kept %d tokens, want at most %d", tokens, model.contextBudget())
+	}
+}
+
+func TestFitContextSkipsToolResults(t *testing.T) {
+	model := ModelConfig{ContextWindow: 400}
+	messages := contextTestHistory(6)
+	// The second user turn's reply called a tool; the kept part can't start with its result
+	call := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, ToolCalls: []openai.ToolCall{{ID: "1", Function: openai.FunctionCall{Name: "list_dir"}}}}
+	result := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, ToolCallID: "1", Content: "main.go"}
+	messages = append(messages[:2], append([]openai.ChatCompletionMessage{call, result}, messages[2:]...)...)
+
+	fit := fitContext(context.Background(), nil, model, messages, nil)
+	if fit.Messages[1].Role != openai.ChatMessageRoleUser {
+		t.Errorf("kept part starts with a %s message, want a user turn", fit.Messages[1].Role)
+	}
+}
+
+func TestFitContextSummaryCache(t *testing.T) {
+	model := ModelConfig{ContextWindow: 400, ContextStrategy: contextStrategySummarize}
+	provider := &summaryStandIn{}
+
+	messages := contextTestHistory(6)
+	fit := fitContext(context.Background(), provider, model, messages, nil)
+	if fit.Err != nil || len(provider.requests) != 1 {
+		t.Fatalf("made %d summary requests (%v), want one", len(provider.requests), fit.Err)
+	}
+	if fit.Summary == nil || fit.Summary.Through != fit.Dropped || fit.Summary.Text != "summary I" {
+		t.Fatalf("summary = %+v after dropping %d messages, want it to cover them", fit.Summary, fit.Dropped)
+	}
+	if fit.Messages[1].Role != openai.ChatMessageRoleSystem || !strings.Contains(fit.Messages[1].Content, "summary I") ||
+		fit.Messages[2].Role != openai.ChatMessageRoleUser {
+		t.Errorf("messages start %s %q, %s; want the summary before a user turn", fit.Messages[1].Role, fit.Messages[1].Content, fit.Messages[2].Role)
+	}
+
+	// The same history reuses the cached summary
+	again := fitContext(context.Background(), provider, model, messages, fit.Summary)
+	if len(provider.requests) != 1 || again.Summary != fit.Summary {
+		t.Errorf("made %d summary requests for an unchanged history, want the cached summary", len(provider.requests))
+	}
+
+	// More turns extend the summary with only the turns it doesn't cover
+	longer := contextTestHistory(8)
+	extended := fitContext(context.Background(), provider, model, longer, fit.Summary)
+	if len(provider.requests) != 2 || extended.Summary.Through != extended.Dropped || extended.Dropped <= fit.Dropped {
+		t.Fatalf("summary = %+v after dropping %d messages, want it extended", extended.Summary, extended.Dropped)
+	}
+	transcript := provider.requests[1].Messages[1].Content
+	if !strings.Contains(transcript, "Summary so far:\nsummary I") || strings.Contains(transcript, longer[1].Content) ||
+		!strings.Contains(transcript, longer[fit.Dropped+1].Content) {
+		t.Errorf("transcript = %q, want the previous summary and only the newly dropped turns", transcript)
+	}
+}
+
+func TestFitContextSummaryFailure(t *testing.T) {
+	model := ModelConfig{ContextWindow: 400, ContextStrategy: contextStrategySummarize}
+	provider := &summaryStandIn{err: errors.New("overloaded")}
+
+	fit := fitContext(context.Background(), provider, model, contextTestHistory(6), nil)
+	if fit.Err == nil || fit.Summary != nil {
+		t.Fatalf("err, summary = %v, %+v; want the error reported", fit.Err, fit.Summary)
+	}
+	if fit.Dropped == 0 || fit.Messages[1].Role != openai.ChatMessageRoleUser {
+		t.Errorf("messages start with %s after dropping %d, want older turns dropped without a summary", fit.Messages[1].Role, fit.Dropped)
+	}
+}
@@ -10,14 +10,56 @@ import (
 	openai "github.com/sashabaranov/go-openai"
 )
 
-// Convos represents a conversation with a title and chat history
+// MessageMeta holds per-message details that are not sent to the provider
+type MessageMeta struct {
+	Interrupted bool   `json:"interrupted,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"`
+
+	// Token usage and cost of the request that produced an assistant message.
+	// UsageEstimated is set when the provider did not report usage.
+	PromptTokens     int     `json:"prompt_tokens,omitempty"`
+	CompletionTokens int     `json:"completion_tokens,omitempty"`
+	UsageEstimated   bool    `json:"usage_estimated,omitempty"`
+	LatencyMs        int64   `json:"latency_ms,omitempty"`
+	Cost             float64 `json:"cost,omitempty"`
+
+	// Provider and Model are the backend that wrote an assistant message, which
+	// differs from the conversation's after a fallback
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	// Reasoning is the model's thinking before the reply. It is kept out of the
+	// message so it is not sent back to the model.
+	Reasoning string `json:"reasoning,omitempty"`
+
+	// Format is "json" for replies to a structured output request; JSONError says
+	// why such a reply failed validation
+	Format    string `json:"format,omitempty"`
+	JSONError string `json:"json_error,omitempty"`
+
+	// Names of the files and images attached to a user message, one for each
+	// message part after the typed text
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// Convos represents a conversation with a title and a tree of messages. Edits and
+// regenerations start new branches; ChatHistory holds the active branch.
 type Convos struct {
-	Title       string                         `json:"title"`
-	ChatHistory []openai.ChatCompletionMessage `json:"chat_history"`
-	Provider    string                         `json:"provider"`
-	Model       string                         `json:"model"`
-	CreatedAt   time.Time                      `json:"created_at"`
-	UpdatedAt   time.Time                      `json:"updated_at"`
+	Title      string            `json:"title"`
+	Nodes      []MessageNode     `json:"nodes"`
+	ActiveLeaf int               `json:"active_leaf"`
+	Summary    *ContextSummary   `json:"summary,omitempty"`
+	Params     *SamplingOverride `json:"params,omitempty"` // sampling parameters set for this chat
+	Usage      ConvoUsage        `json:"usage"`
+	Provider   string            `json:"provider"`
+	Model      string            `json:"model"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+
+	// The active branch from the root to ActiveLeaf, rebuilt from Nodes
+	ChatHistory []openai.ChatCompletionMessage `json:"-"`
+	Meta        []MessageMeta                  `json:"-"`
+	pathIDs     []int
 }
 
 // NewConvos creates a new conversation with the given title, provider, and model
@@ -25,6 +67,7 @@ func NewConvos(title, provider, model string) *Convos {
 	now := time.Now()
 	return &Convos{
 		Title:       title,
+		Nodes:       []MessageNode{},
 		ChatHistory: []openai.ChatCompletionMessage{},
 		Provider:    provider,
 		Model:       model,
@@ -33,15 +76,43 @@ func NewConvos(title, provider, model string) *Convos {
 	}
 }
 
-// AddMessage adds a message to the conversation history
+// AddMessage adds a message to the end of the active branch
 func (c *Convos) AddMessage(role, content string) {
-	c.ChatHistory = append(c.ChatHistory, openai.ChatCompletionMessage{
-		Role:    role,
-		Content: content,
-	})
+	c.AddMessageWithMeta(role, content, MessageMeta{})
+}
+
+// AddMessageWithMeta adds a message along with its metadata to the end of the active branch
+func (c *Convos) AddMessageWithMeta(role, content string, meta MessageMeta) {
+	c.AddChatMessage(openai.ChatCompletionMessage{Role: role, Content: content}, meta)
+}
+
+// AddChatMessage adds a complete message, such as a tool call or tool result, to the
+// end of the active branch
+func (c *Convos) AddChatMessage(message openai.ChatCompletionMessage, meta MessageMeta) {
+	node := MessageNode{
+		ID:       c.nextNodeID(),
+		ParentID: c.ActiveLeaf,
+		Message:  message,
+		Meta:     meta,
+	}
+	c.Nodes = append(c.Nodes, node)
+	c.ActiveLeaf = node.ID
+
+	c.ChatHistory = append(c.ChatHistory, node.Message)
+	c.Meta = append(c.Meta, node.Meta)
+	c.pathIDs = append(c.pathIDs, node.ID)
+	c.Usage.add(meta)
 	c.UpdatedAt = time.Now()
 }
 
+// MetaAt returns the metadata for the message at the given index of the active branch
+func (c *Convos) MetaAt(index int) MessageMeta {
+	if index < 0 || index >= len(c.Meta) {
+		return MessageMeta{}
+	}
+	return c.Meta[index]
+}
+
 // GetChatHistoryDir returns the directory path for storing chat history
 func GetChatHistoryDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
@@ -118,6 +189,16 @@ func LoadConvos(filePath string) (*Convos, error) {
 		return nil, fmt.Errorf("failed to unmarshal conversation: %w", err)
 	}
 
+	// Conversations saved before branching existed hold a flat chat history
+	if len(convo.Nodes) == 0 {
+		var legacy legacyConvos
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal conversation: %w", err)
+		}
+		convo.importFlatHistory(legacy)
+	}
+	convo.rebuildPath()
+
 	return &convo, nil
 }
 
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// MessageNode is one message in a conversation tree. Messages with the same
+// parent are alternative branches, e.g. a regenerated reply or an edited prompt.
+type MessageNode struct {
+	ID       int                          `json:"id"`
+	ParentID int                          `json:"parent_id"` // 0 for the first message
+	Message  openai.ChatCompletionMessage `json:"message"`
+	Meta     MessageMeta                  `json:"meta"`
+}
+
+// legacyConvos is the flat chat history format of older conversation files
+type legacyConvos struct {
+	ChatHistory []openai.ChatCompletionMessage `json:"chat_history"`
+	Meta        []MessageMeta                  `json:"meta"`
+}
+
+// importFlatHistory turns a flat chat history into a single branch
+func (c *Convos) importFlatHistory(legacy legacyConvos) {
+	c.Nodes = []MessageNode{}
+	parent := 0
+	for i, msg := range legacy.ChatHistory {
+		var meta MessageMeta
+		if i < len(legacy.Meta) {
+			meta = legacy.Meta[i]
+		}
+		parent = c.appendNode(parent, msg, meta)
+	}
+	c.ActiveLeaf = parent
+}
+
+// appendNode adds a node under the given parent and returns its ID
+func (c *Convos) appendNode(parentID int, msg openai.ChatCompletionMessage, meta MessageMeta) int {
+	id := c.nextNodeID()
+	c.Nodes = append(c.Nodes, MessageNode{ID: id, ParentID: parentID, Message: msg, Meta: meta})
+	return id
+}
+
+// nextNodeID returns an ID not used by any node yet
+func (c *Convos) nextNodeID() int {
+	next := 1
+	for _, node := range c.Nodes {
+		next = max(next, node.ID+1)
+	}
+	return next
+}
+
+// node returns the node with the given ID, or nil
+func (c *Convos) node(id int) *MessageNode {
+	for i := range c.Nodes {
+		if c.Nodes[i].ID == id {
+			return &c.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// children returns the IDs of a node's children in the order they were added
+func (c *Convos) children(parentID int) []int {
+	var ids []int
+	for _, node := range c.Nodes {
+		if node.ParentID == parentID {
+			ids = append(ids, node.ID)
+		}
+	}
+	return ids
+}
+
+// rebuildPath refreshes ChatHistory and Meta from the root to the active leaf
+func (c *Convos) rebuildPath() {
+	var path []*MessageNode
+	for id := c.ActiveLeaf; id != 0; {
+		node := c.node(id)
+		if node == nil {
+			break
+		}
+		path = append(path, node)
+		id = node.ParentID
+	}
+
+	c.ChatHistory = make([]openai.ChatCompletionMessage, 0, len(path))
+	c.Meta = make([]MessageMeta, 0, len(path))
+	c.pathIDs = make([]int, 0, len(path))
+	for i := len(path) - 1; i >= 0; i-- {
+		c.ChatHistory = append(c.ChatHistory, path[i].Message)
+		c.Meta = append(c.Meta, path[i].Meta)
+		c.pathIDs = append(c.pathIDs, path[i].ID)
+	}
+}
+
+// setActiveLeaf switches the active branch. The context summary is kept only if
+// the new branch still starts with all the turns it covers.
+func (c *Convos) setActiveLeaf(id int) {
+	summarized, ok := c.summarizedIDs()
+	c.ActiveLeaf = id
+	c.rebuildPath()
+	c.UpdatedAt = time.Now()
+
+	if !ok || len(summarized) > len(c.pathIDs) {
+		c.Summary = nil
+		return
+	}
+	for i, id := range summarized {
+		if c.pathIDs[i] != id {
+			c.Summary = nil
+			return
+		}
+	}
+}
+
+// summarizedIDs returns the IDs of the active branch's messages covered by the
+// context summary, including the system prompt before them. ok is false if the
+// summary covers more than the active branch holds.
+func (c *Convos) summarizedIDs() (ids []int, ok bool) {
+	if c.Summary == nil {
+		return nil, true
+	}
+	through := c.Summary.Through
+	if len(c.ChatHistory) > 0 && c.ChatHistory[0].Role == openai.ChatMessageRoleSystem {
+		through++
+	}
+	if through > len(c.pathIDs) {
+		return nil, false
+	}
+	return c.pathIDs[:through], true
+}
+
+// RemoveLastMessage deletes the last message of the active branch, e.g. a user
+// message that never got a reply
+func (c *Convos) RemoveLastMessage() {
+	last := c.node(c.ActiveLeaf)
+	if last == nil {
+		return
+	}
+
+	parentID := last.ParentID
+	if len(c.children(last.ID)) == 0 {
+		for i := range c.Nodes {
+			if c.Nodes[i].ID == last.ID {
+				c.Nodes = append(c.Nodes[:i], c.Nodes[i+1:]...)
+				break
+			}
+		}
+	}
+	c.setActiveLeaf(parentID)
+}
+
+// ForkAt moves the end of the active branch to just before the message at the
+// given index, so the next message added starts a new branch there. The old
+// messages stay in the tree.
+func (c *Convos) ForkAt(index int) {
+	if index < 0 || index >= len(c.pathIDs) {
+		return
+	}
+	parentID := 0
+	if index > 0 {
+		parentID = c.pathIDs[index-1]
+	}
+	c.setActiveLeaf(parentID)
+}
+
+// BranchPosition returns the 1-based position of the message at the given index
+// among its sibling branches, and how many siblings there are
+func (c *Convos) BranchPosition(index int) (int, int) {
+	if index < 0 || index >= len(c.pathIDs) {
+		return 0, 0
+	}
+	node := c.node(c.pathIDs[index])
+	siblings := c.children(node.ParentID)
+	for i, id := range siblings {
+		if id == node.ID {
+			return i + 1, len(siblings)
+		}
+	}
+	return 0, len(siblings)
+}
+
+// SwitchBranch replaces the message at the given index with its next (step 1) or
+// previous (step -1) sibling, wrapping around, and follows that branch to its most
+// recent leaf. It reports whether there was another branch to switch to.
+func (c *Convos) SwitchBranch(index, step int) bool {
+	position, total := c.BranchPosition(index)
+	if total < 2 {
+		return false
+	}
+
+	node := c.node(c.pathIDs[index])
+	siblings := c.children(node.ParentID)
+	next := siblings[(position-1+step+total)%total]
+
+	// Follow the most recently added child down to a leaf
+	leaf := next
+	for {
+		children := c.children(leaf)
+		if len(children) == 0 {
+			break
+		}
+		leaf = children[len(children)-1]
+	}
+
+	c.setActiveLeaf(leaf)
+	return true
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestLoadConvosFlatHistory(t *testing.T) {
+	// A conversation file as saved before the conversation tree existed
+	path := filepath.Join(t.TempDir(), "chat.json")
+	err := os.WriteFile(path, []byte(`{
+  "title": "Old chat",
+  "chat_history": [
+    {"role": "system", "content": "Be brief."},
+    {"role": "user", "content": "Hi"},
+    {"role": "assistant", "content": "Hello!"},
+    {"role": "user", "content": "Bye"},
+    {"role": "assistant", "content": "Goodbye!"}
+  ],
+  "provider": "openai",
+  "model": "gpt-4o",
+  "created_at": "2025-01-02T10:00:00Z",
+  "updated_at": "2025-01-02T10:05:00Z"
+}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	convo, err := LoadConvos(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Be brief.", "Hi", "Hello!", "Bye", "Goodbye!"}
+	if len(convo.ChatHistory) != len(want) || len(convo.Nodes) != len(want) {
+		t.Fatalf("got %d messages in %d nodes, want %d in one branch", len(convo.ChatHistory), len(convo.Nodes), len(want))
+	}
+	for i, content := range want {
+		if convo.ChatHistory[i].Content != content {
+			t.Errorf("message %d = %q, want %q", i, convo.ChatHistory[i].Content, content)
+		}
+		if position, total := convo.BranchPosition(i); position != 1 || total != 1 {
+			t.Errorf("message %d is branch %d of %d, want the only branch", i, position, total)
+		}
+	}
+	if convo.Title != "Old chat" || convo.Model != "gpt-4o" {
+		t.Errorf("title, model = %q, %q; want them kept", convo.Title, convo.Model)
+	}
+
+	// Replies added after loading continue the same branch
+	convo.AddMessage(openai.ChatMessageRoleUser, "One more")
+	if parent := convo.node(convo.ActiveLeaf).ParentID; convo.node(parent).Message.Content != "Goodbye!" {
+		t.Errorf("new message follows %q, want the last imported reply", convo.node(parent).Message.Content)
+	}
+}
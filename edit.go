@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+var (
+	selectedMessage = -1 // history index of the user message selected in the chat log
+	editingMessage  = -1 // history index of the user message being edited in the input view
+)
+
+// clearMessageSelection forgets the selected and edited messages
+func clearMessageSelection() {
+	selectedMessage = -1
+	editingMessage = -1
+}
+
+// findUserMessage returns the index of the nearest user message from the given
+// index in the given direction, or -1 if there is none
+func findUserMessage(from, step int) int {
+	for i := from; i >= 0 && i < len(currentConvo.ChatHistory); i += step {
+		if currentConvo.ChatHistory[i].Role == openai.ChatMessageRoleUser {
+			return i
+		}
+	}
+	return -1
+}
+
+// Select the previous user message in the chat log
+func selectPreviousUserMessage(g *gocui.Gui, v *gocui.View) error {
+	from := selectedMessage - 1
+	if selectedMessage < 0 {
+		from = len(currentConvo.ChatHistory) - 1
+	}
+	if index := findUserMessage(from, -1); index >= 0 {
+		selectedMessage = index
+	}
+	renderChatLog(v)
+	return nil
+}
+
+// Select the next user message in the chat log, or clear the selection past the last one
+func selectNextUserMessage(g *gocui.Gui, v *gocui.View) error {
+	if selectedMessage < 0 {
+		return nil
+	}
+	selectedMessage = findUserMessage(selectedMessage+1, 1)
+	renderChatLog(v)
+	return nil
+}
+
+// Load the selected user message into the input view for editing
+func editSelectedMessage(g *gocui.Gui, v *gocui.View) error {
+	if streaming || selectedMessage < 0 {
+		return nil
+	}
+
+	inputView, err := g.View("input")
+	if err != nil {
+		return err
+	}
+
+	msg := currentConvo.ChatHistory[selectedMessage]
+	meta := currentConvo.MetaAt(selectedMessage)
+	content := strings.TrimRight(messageInput(msg, meta), "\n")
+
+	// The message's attachments are sent again unless dropped with /attach clear
+	pendingAttachments = messageAttachments(msg, meta)
+
+	inputView.Clear()
+	fmt.Fprint(inputView, content)
+	lines := strings.Split(content, "\n")
+	inputView.SetCursor(len(lines[len(lines)-1]), len(lines)-1)
+
+	editingMessage = selectedMessage
+	if _, err := setCurrentViewOnTop(g, "input"); err != nil {
+		return err
+	}
+	g.Cursor = true
+	active = 4
+
+	setStatus(g, "Editing message: Enter to resend from here, Esc to cancel")
+	return nil
+}
+
+// cancelEdit abandons the message edit in progress
+func cancelEdit(g *gocui.Gui) error {
+	inputView, err := g.View("input")
+	if err != nil {
+		return err
+	}
+	inputView.Clear()
+	inputView.SetCursor(0, 0)
+
+	if editingMessage >= 0 {
+		pendingAttachments = nil
+	}
+	clearMessageSelection()
+	setStatus(g, "")
+	if chatLogView, err := g.View("chatLog"); err == nil {
+		renderChatLog(chatLogView)
+	}
+	return nil
+}
+
+// resendEditedMessage starts a new branch at the edited message and sends the
+// new text there. The original message and its replies stay in the tree.
+func resendEditedMessage(g *gocui.Gui, inputText string) error {
+	index := editingMessage
+	clearMessageSelection()
+
+	currentConvo.ForkAt(index)
+	if err := saveCurrentConversation(); err != nil {
+		setStatus(g, "\033[31mFailed to save conversation: "+err.Error()+"\033[0m")
+	}
+
+	return sendMessage(g, inputText)
+}
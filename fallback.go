@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// fallbackChain returns the configured fallback backends to try when the given
+// provider/model fails, leaving out that pair itself
+func fallbackChain(providerName, modelName string) []string {
+	chain := make([]string, 0, len(config.Fallback))
+	for _, entry := range config.Fallback {
+		if entry != providerName+"/"+modelName {
+			chain = append(chain, entry)
+		}
+	}
+	return chain
+}
+
+// validateFallback checks that every fallback entry names a configured provider
+func (c *Config) validateFallback() error {
+	for _, entry := range c.Fallback {
+		if err := c.checkBackend(entry); err != nil {
+			return fmt.Errorf("fallback: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkBackend checks that a provider/model entry names a configured provider
+func (c *Config) checkBackend(entry string) error {
+	providerName, modelName, found := strings.Cut(entry, "/")
+	if !found || modelName == "" {
+		return fmt.Errorf("%q must be provider/model", entry)
+	}
+	if _, exists := c.Providers[providerName]; !exists {
+		return fmt.Errorf("%q: provider not found: %s", entry, providerName)
+	}
+	return nil
+}
+
+// fallBack switches the job and its request to the next backend of the fallback
+// chain, skipping backends that can't be created. It returns false once the chain
+// is used up. The request's messages still need fitting to the new model.
+func (job *completionJob) fallBack(request *ChatRequest) bool {
+	for len(job.fallbacks) > 0 {
+		entry := job.fallbacks[0]
+		job.fallbacks = job.fallbacks[1:]
+
+		target, err := parseCompareTarget(entry)
+		if err != nil {
+			log.Printf("Skipping fallback %s: %v", entry, err)
+			continue
+		}
+		providerConfig, err := config.GetProviderConfig(target.provider)
+		if err != nil {
+			log.Printf("Skipping fallback %s: %v", entry, err)
+			continue
+		}
+		provider, err := newProvider(target.provider, *providerConfig)
+		if err != nil {
+			log.Printf("Skipping fallback %s: %v", entry, err)
+			continue
+		}
+
+		model := job.params.apply(target.model)
+		job.providerName = target.provider
+		job.provider = provider
+		job.model = model
+		job.policy = providerConfig.retryPolicy()
+
+		request.Model = model.Name
+		request.Temperature = model.Temperature
+		request.Sampling = model.SamplingParams
+		request.ResponseFormat = model.responseFormat()
+		request.Tools = model.requestTools()
+		return true
+	}
+	return false
+}
+
+// backend returns the provider and model the job currently sends to, for a
+// reply's metadata
+func (job *completionJob) backend() MessageMeta {
+	return MessageMeta{Provider: job.providerName, Model: job.model.Name}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreRule is one pattern line of a .gitignore file
+type gitignoreRule struct {
+	base     string // directory of the .gitignore file, slash-separated and relative to the walk root
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool // the pattern contains a slash, so it matches from base only
+}
+
+// gitignore holds the rules of the .gitignore files found while walking a directory
+type gitignore struct {
+	rules []gitignoreRule
+}
+
+// load adds the rules of the .gitignore file in dir, if there is one. rel is dir
+// relative to the walk root.
+func (g *gitignore) load(dir, rel string) {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	base := filepath.ToSlash(rel)
+	if base == "." {
+		base = ""
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := gitignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// A leading "**/" is left for matchGitignorePath, so the pattern matches at any depth
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		rule.pattern = line
+		g.rules = append(g.rules, rule)
+	}
+}
+
+// ignored reports whether the path (relative to the walk root) is ignored.
+// Later rules override earlier ones, as in git.
+func (g *gitignore) ignored(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(rel)
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		target := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(rel, rule.base+"/")
+		}
+
+		var matched bool
+		if rule.anchored {
+			matched = matchGitignorePath(rule.pattern, target)
+		} else {
+			matched, _ = path.Match(rule.pattern, path.Base(target))
+		}
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchGitignorePath matches a slash-containing pattern against a relative path,
+// letting a "**" segment stand for any number of directories
+func matchGitignorePath(pattern, target string) bool {
+	return matchGitignoreSegments(strings.Split(pattern, "/"), strings.Split(target, "/"))
+}
+
+// matchGitignoreSegments matches pattern segments against path segments one by one
+func matchGitignoreSegments(pattern, target []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// A trailing "**" matches everything inside the directory, at any depth
+			if len(pattern) == 1 {
+				return len(target) > 0
+			}
+			for i := range len(target) + 1 {
+				if matchGitignoreSegments(pattern[1:], target[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(target) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], target[0]); !matched {
+			return false
+		}
+		pattern, target = pattern[1:], target[1:]
+	}
+	return len(target) == 0
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchGitignorePath(t *testing.T) {
+	for _, test := range []struct {
+		pattern, target string
+		want            bool
+	}{
+		{"**/logs", "logs", true},
+		{"**/logs", "a/b/logs", true},
+		{"**/logs/*.txt", "a/logs/x.txt", true},
+		{"**/logs/*.txt", "a/logs/b/x.txt", false},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "c/a/x/b", false},
+		{"a/**/b", "a/x/bc", false},
+		{"foo/**", "foo/a", true},
+		{"foo/**", "foo/a/b/c.go", true},
+		{"foo/**", "foo", false},
+		{"foo/**", "bar/foo/a", false},
+		{"doc/*.md", "doc/readme.md", true},
+		{"doc/*.md", "doc/api/readme.md", false},
+	} {
+		if got := matchGitignorePath(test.pattern, test.target); got != test.want {
+			t.Errorf("matchGitignorePath(%q, %q) = %t, want %t", test.pattern, test.target, got, test.want)
+		}
+	}
+}
+
+func TestGitignoreRules(t *testing.T) {
+	root := t.TempDir()
+	writeGitignore := func(dir, rules string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, dir, ".gitignore"), []byte(rules), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeGitignore(".", "# build output\n*.log\n!keep.log\nbuild/\n/vendor\ncache/**\n")
+	writeGitignore("web", "dist\n*.js\n!*.min.js\n")
+
+	ignore := &gitignore{}
+	ignore.load(root, ".")
+	ignore.load(filepath.Join(root, "web"), "web")
+
+	for _, test := range []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"src/deep/app.log", false, true},
+		{"keep.log", false, false},
+		{"src/keep.log", false, false},
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false},
+		{"vendor", true, true},
+		{"src/vendor", true, false},
+		{"cache/a/b/data.bin", false, true},
+		{"cache", true, false},
+		{"web/dist", true, true},
+		{"web/src/app.js", false, true},
+		{"web/src/app.min.js", false, false},
+		{"app.js", false, false},
+		{"dist", true, false},
+		{"main.go", false, false},
+	} {
+		if got := ignore.ignored(test.rel, test.isDir); got != test.want {
+			t.Errorf("ignored(%q, dir: %t) = %t, want %t", test.rel, test.isDir, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	// Largest image that can be attached to a message
+	maxImageSize = 20 * 1024 * 1024
+	// Rough token cost of an image, used for context window estimates
+	imageTokenEstimate = 765
+)
+
+// imageMediaTypes maps the image extensions that can be attached to their media types
+var imageMediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// loadImage reads a local image and encodes it as a data URL message part
+func loadImage(path string) (attachment, error) {
+	mediaType, supported := imageMediaTypes[strings.ToLower(filepath.Ext(path))]
+	if !supported {
+		return attachment{}, fmt.Errorf("unsupported image type %q (use png, jpeg, gif or webp)", filepath.Ext(path))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return attachment{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	if info.Size() > maxImageSize {
+		return attachment{}, fmt.Errorf("image %s is larger than %d MB", filepath.Base(path), maxImageSize/1024/1024)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return attachment{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	if detected := http.DetectContentType(data); strings.HasPrefix(detected, "image/") {
+		mediaType = detected
+	}
+
+	return attachment{
+		name:   filepath.Base(path),
+		tokens: imageTokenEstimate,
+		part: openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{
+				URL:    "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data),
+				Detail: openai.ImageURLDetailAuto,
+			},
+		},
+	}, nil
+}
+
+// imageCommand handles /image, attaching images to the next message
+func imageCommand(g *gocui.Gui, args []string) error {
+	if len(args) == 0 {
+		setStatus(g, "Usage: "+commands["image"].usage)
+		return nil
+	}
+	if len(args) == 1 && args[0] == "clear" {
+		return clearAttachments(g)
+	}
+
+	// A single path may contain spaces
+	paths := args
+	if joined := strings.Join(args, " "); len(args) > 1 && fileExists(joined) {
+		paths = []string{joined}
+	}
+
+	for _, path := range paths {
+		if err := attachImage(g, unquotePath(path)); err != nil {
+			setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+			return nil
+		}
+	}
+	return nil
+}
+
+// attachImage adds an image to the next message
+func attachImage(g *gocui.Gui, path string) error {
+	image, err := loadImage(path)
+	if err != nil {
+		return err
+	}
+	pendingAttachments = append(pendingAttachments, image)
+	setStatus(g, "Attached "+image.name+" to the next message")
+	return nil
+}
+
+// droppedImagePath returns the image path when the input is nothing but the path
+// of an existing image file, as pasted by dragging a file into the terminal
+func droppedImagePath(input string) (string, bool) {
+	path := unquotePath(strings.TrimSpace(input))
+	if _, supported := imageMediaTypes[strings.ToLower(filepath.Ext(path))]; !supported {
+		return "", false
+	}
+	return path, fileExists(path)
+}
+
+// unquotePath undoes the quoting terminals apply to dragged-in paths
+func unquotePath(path string) string {
+	path = strings.TrimPrefix(path, "file://")
+	if len(path) >= 2 && (path[0] == '\'' || path[0] == '"') && path[len(path)-1] == path[0] {
+		return path[1 : len(path)-1]
+	}
+	return strings.ReplaceAll(path, `\ `, " ")
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// parseDataURL splits a base64 data URL into its media type and data
+func parseDataURL(url string) (string, string, bool) {
+	header, data, found := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !found || !strings.HasPrefix(url, "data:") || !strings.HasSuffix(header, ";base64") {
+		return "", "", false
+	}
+	return strings.TrimSuffix(header, ";base64"), data, true
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// validateJSONSchema checks a decoded JSON value against a JSON schema. It covers
+// the keywords structured output schemas use: type, enum, const, properties,
+// required, additionalProperties, items, the length, size and range limits,
+// pattern, allOf/anyOf/oneOf and local $ref. The error names the failing path.
+func validateJSONSchema(schema, value any) error {
+	root, _ := schema.(map[string]any)
+	return validateJSONValue(root, root, value, "$")
+}
+
+func validateJSONValue(root, schema map[string]any, value any, path string) error {
+	if schema == nil {
+		return nil
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := resolveJSONRef(root, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return validateJSONValue(root, resolved, value, path)
+	}
+
+	if types, ok := schemaTypes(schema["type"]); ok && !matchesAnyType(value, types) {
+		return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeName(value))
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, option := range enum {
+			if reflect.DeepEqual(option, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value is not one of the allowed values", path)
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		return fmt.Errorf("%s: value must be %v", path, constant)
+	}
+
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		subschemas, ok := schema[keyword].([]any)
+		if !ok {
+			continue
+		}
+		matches := 0
+		var firstErr error
+		for _, subschema := range subschemas {
+			sub, _ := subschema.(map[string]any)
+			if err := validateJSONValue(root, sub, value, path); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			matches++
+		}
+		switch {
+		case keyword == "allOf" && matches < len(subschemas):
+			return firstErr
+		case keyword == "anyOf" && matches == 0:
+			return fmt.Errorf("%s: value matches none of the anyOf schemas", path)
+		case keyword == "oneOf" && matches != 1:
+			return fmt.Errorf("%s: value matches %d of the oneOf schemas instead of one", path, matches)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		return validateJSONObject(root, schema, v, path)
+	case []any:
+		return validateJSONArray(root, schema, v, path)
+	case string:
+		length := len([]rune(v))
+		if limit, ok := schemaNumber(schema, "minLength"); ok && float64(length) < limit {
+			return fmt.Errorf("%s: string is shorter than %v characters", path, limit)
+		}
+		if limit, ok := schemaNumber(schema, "maxLength"); ok && float64(length) > limit {
+			return fmt.Errorf("%s: string is longer than %v characters", path, limit)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err == nil && !re.MatchString(v) {
+				return fmt.Errorf("%s: string does not match pattern %s", path, pattern)
+			}
+		}
+	case float64:
+		if limit, ok := schemaNumber(schema, "minimum"); ok && v < limit {
+			return fmt.Errorf("%s: %v is less than the minimum %v", path, v, limit)
+		}
+		if limit, ok := schemaNumber(schema, "maximum"); ok && v > limit {
+			return fmt.Errorf("%s: %v is more than the maximum %v", path, v, limit)
+		}
+	}
+	return nil
+}
+
+func validateJSONObject(root, schema map[string]any, object map[string]any, path string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, exists := object[key]; !exists {
+					return fmt.Errorf("%s: missing required property %q", path, key)
+				}
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		childPath := path + "." + key
+		if property, exists := properties[key]; exists {
+			sub, _ := property.(map[string]any)
+			if err := validateJSONValue(root, sub, object[key], childPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: unexpected property", childPath)
+			}
+		case map[string]any:
+			if err := validateJSONValue(root, additional, object[key], childPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateJSONArray(root, schema map[string]any, array []any, path string) error {
+	if limit, ok := schemaNumber(schema, "minItems"); ok && float64(len(array)) < limit {
+		return fmt.Errorf("%s: array has fewer than %v items", path, limit)
+	}
+	if limit, ok := schemaNumber(schema, "maxItems"); ok && float64(len(array)) > limit {
+		return fmt.Errorf("%s: array has more than %v items", path, limit)
+	}
+
+	items, _ := schema["items"].(map[string]any)
+	for i, item := range array {
+		if err := validateJSONValue(root, items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveJSONRef finds a local reference such as "#/$defs/address" in the root schema
+func resolveJSONRef(root map[string]any, ref string) (map[string]any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+
+	current := root
+	for _, segment := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(ref, "#"), "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		next, ok := current[segment].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// schemaTypes reads the type keyword, which may be a single type or a list
+func schemaTypes(value any) ([]string, bool) {
+	switch t := value.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		var types []string
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+func matchesAnyType(value any, types []string) bool {
+	for _, t := range types {
+		if t == jsonTypeName(value) || (t == "number" && jsonTypeName(value) == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON schema type of a decoded value
+func jsonTypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		return "number"
+	}
+	return "unknown"
+}
+
+func schemaNumber(schema map[string]any, keyword string) (float64, bool) {
+	number, ok := schema[keyword].(float64)
+	return number, ok
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// recipeSchema is a nested schema like the ones structured output uses
+const recipeSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"servings": {"type": "integer", "minimum": 1, "maximum": 12},
+		"difficulty": {"enum": ["easy", "medium", "hard"]},
+		"ingredients": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"properties": {
+					"item": {"type": "string"},
+					"grams": {"type": ["number", "null"]}
+				},
+				"required": ["item", "grams"],
+				"additionalProperties": false
+			}
+		},
+		"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}}
+	},
+	"required": ["name", "ingredients"],
+	"additionalProperties": false,
+	"$defs": {"tag": {"type": "string", "pattern": "^[a-z]+$"}}
+}`
+
+func TestValidateJSONSchema(t *testing.T) {
+	var schema any
+	if err := json.Unmarshal([]byte(recipeSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		value string
+		err   string // empty when the value is valid
+	}{
+		{"valid", `{"name": "Soup", "servings": 4, "difficulty": "easy", "ingredients": [{"item": "leek", "grams": 200}, {"item": "salt", "grams": null}], "tags": ["vegan"]}`, ""},
+		{"not an object", `["Soup"]`, "$: expected object, got array"},
+		{"missing required", `{"name": "Soup"}`, `$: missing required property "ingredients"`},
+		{"wrong type", `{"name": 3, "ingredients": [{"item": "leek", "grams": 1}]}`, "$.name: expected string, got integer"},
+		{"integer", `{"name": "Soup", "servings": 2.5, "ingredients": [{"item": "leek", "grams": 1}]}`, "$.servings: expected integer, got number"},
+		{"minimum", `{"name": "Soup", "servings": 0, "ingredients": [{"item": "leek", "grams": 1}]}`, "$.servings: 0 is less than the minimum 1"},
+		{"maximum", `{"name": "Soup", "servings": 20, "ingredients": [{"item": "leek", "grams": 1}]}`, "$.servings: 20 is more than the maximum 12"},
+		{"min length", `{"name": "", "ingredients": [{"item": "leek", "grams": 1}]}`, "$.name: string is shorter than 1 characters"},
+		{"enum", `{"name": "Soup", "difficulty": "extreme", "ingredients": [{"item": "leek", "grams": 1}]}`, "$.difficulty: value is not one of the allowed values"},
+		{"min items", `{"name": "Soup", "ingredients": []}`, "$.ingredients: array has fewer than 1 items"},
+		{"nested required", `{"name": "Soup", "ingredients": [{"item": "leek"}]}`, `$.ingredients[0]: missing required property "grams"`},
+		{"nested type list", `{"name": "Soup", "ingredients": [{"item": "leek", "grams": "lots"}]}`, "$.ingredients[0].grams: expected number or null, got string"},
+		{"additional property", `{"name": "Soup", "ingredients": [{"item": "leek", "grams": 1}], "price": 3}`, "$.price: unexpected property"},
+		{"nested additional property", `{"name": "Soup", "ingredients": [{"item": "leek", "grams": 1, "note": "fresh"}]}`, "$.ingredients[0].note: unexpected property"},
+		{"ref", `{"name": "Soup", "ingredients": [{"item": "leek", "grams": 1}], "tags": ["Vegan"]}`, "$.tags[0]: string does not match pattern ^[a-z]+$"},
+	} {
+		var value any
+		if err := json.Unmarshal([]byte(test.value), &value); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		err := validateJSONSchema(schema, value)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("%s: error = %v, want %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestValidateJSONSchemaCombinators(t *testing.T) {
+	for _, test := range []struct {
+		schema string
+		value  string
+		err    string
+	}{
+		{`{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `3`, ""},
+		{`{"anyOf": [{"type": "string"}, {"type": "integer"}]}`, `true`, "$: value matches none of the anyOf schemas"},
+		{`{"oneOf": [{"type": "number"}, {"type": "integer"}]}`, `3`, "$: value matches 2 of the oneOf schemas instead of one"},
+		{`{"allOf": [{"type": "string"}, {"maxLength": 2}]}`, `"abc"`, "$: string is longer than 2 characters"},
+		{`{"const": "yes"}`, `"no"`, "$: value must be yes"},
+		{`{"additionalProperties": {"type": "boolean"}}`, `{"a": true, "b": 1}`, "$.b: expected boolean, got integer"},
+		{`{"$ref": "#/$defs/missing"}`, `1`, `$: unresolved $ref "#/$defs/missing"`},
+	} {
+		var schema, value any
+		if err := json.Unmarshal([]byte(test.schema), &schema); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(test.value), &value); err != nil {
+			t.Fatal(err)
+		}
+		err := validateJSONSchema(schema, value)
+		if (err == nil) != (test.err == "") || err != nil && err.Error() != test.err {
+			t.Errorf("%s with %s: error = %v, want %q", test.schema, test.value, err, test.err)
+		}
+	}
+}
+
+func TestCheckJSONReply(t *testing.T) {
+	format := &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   "recipe",
+			Schema: json.RawMessage(recipeSchema),
+		},
+	}
+
+	for _, test := range []struct {
+		reply string
+		want  string // prefix of the message shown in the UI, empty when valid
+	}{
+		{"```json\n{\"name\": \"Soup\", \"ingredients\": [{\"item\": \"leek\", \"grams\": 1}]}\n```", ""},
+		{`Here is your recipe: {"name": "Soup"}`, "not valid JSON: "},
+		{`{"name": "Soup"}`, `does not match the schema: $: missing required property "ingredients"`},
+	} {
+		got := checkJSONReply(test.reply, format)
+		if test.want == "" && got != "" || !strings.HasPrefix(got, test.want) {
+			t.Errorf("checkJSONReply(%q) = %q, want %q", test.reply, got, test.want)
+		}
+	}
+
+	if got := checkJSONReply(`{"anything": true}`, &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}); got != "" {
+		t.Errorf("json_object reply = %q, want any JSON accepted", got)
+	}
+}
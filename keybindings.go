@@ -38,6 +38,18 @@ func keybindings(g *gocui.Gui) error {
 		return err
 	}
 
+	// Add 'r' key binding to refresh the discovered models
+	err = g.SetKeybinding("models", 'r', gocui.ModNone, refreshModels)
+	if err != nil {
+		return err
+	}
+
+	// Add 'p' key binding to open the active model's parameters
+	err = g.SetKeybinding("models", 'p', gocui.ModNone, openParams)
+	if err != nil {
+		return err
+	}
+
 	// Arrow keys for navigating the providers list when providers view is active
 	err = g.SetKeybinding("providers", gocui.KeyArrowUp, gocui.ModNone, moveProviderUp)
 	if err != nil {
@@ -106,6 +118,200 @@ func keybindings(g *gocui.Gui) error {
 		return err
 	}
 
+	// Esc or Ctrl+X cancels the in-flight completion from the input and chat log views
+	for _, view := range []string{"input", "chatLog"} {
+		err = g.SetKeybinding(view, gocui.KeyEsc, gocui.ModNone, cancelCompletion)
+		if err != nil {
+			return err
+		}
+
+		err = g.SetKeybinding(view, gocui.KeyCtrlX, gocui.ModNone, cancelCompletion)
+		if err != nil {
+			return err
+		}
+
+		// Ctrl+R resends the last message that failed to get a reply
+		err = g.SetKeybinding(view, gocui.KeyCtrlR, gocui.ModNone, resendFailedTurn)
+		if err != nil {
+			return err
+		}
+
+		// Ctrl+G regenerates the last assistant response
+		err = g.SetKeybinding(view, gocui.KeyCtrlG, gocui.ModNone, regenerateResponse)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Arrow keys (or 'k'/'j') select an earlier user message and 'e' edits it
+	err = g.SetKeybinding("chatLog", gocui.KeyArrowUp, gocui.ModNone, selectPreviousUserMessage)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("chatLog", gocui.KeyArrowDown, gocui.ModNone, selectNextUserMessage)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("chatLog", 'k', gocui.ModNone, selectPreviousUserMessage)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("chatLog", 'j', gocui.ModNone, selectNextUserMessage)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("chatLog", 'e', gocui.ModNone, editSelectedMessage)
+	if err != nil {
+		return err
+	}
+
+	// '[' and ']' switch between branches of the selected (or last) message
+	err = g.SetKeybinding("chatLog", '[', gocui.ModNone, previousBranch)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("chatLog", ']', gocui.ModNone, nextBranch)
+	if err != nil {
+		return err
+	}
+
+	// 'r' expands or collapses the reasoning of replies
+	err = g.SetKeybinding("chatLog", 'r', gocui.ModNone, toggleReasoning)
+	if err != nil {
+		return err
+	}
+
+	// 't' shows or hides the conversation tree
+	err = g.SetKeybinding("chatLog", 't', gocui.ModNone, toggleTree)
+	if err != nil {
+		return err
+	}
+
+	// 'y' and 'n' answer the confirmation prompt for tool calls; Esc declines
+	err = g.SetKeybinding("confirm", 'y', gocui.ModNone, confirmYes)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("confirm", 'n', gocui.ModNone, confirmNo)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("confirm", gocui.KeyEsc, gocui.ModNone, confirmNo)
+	if err != nil {
+		return err
+	}
+
+	// The MCP server list opened with /mcp: arrows or 'k'/'j' to move, Space or Enter to toggle
+	err = g.SetKeybinding("mcpServers", gocui.KeyArrowUp, gocui.ModNone, moveMCPServerUp)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("mcpServers", gocui.KeyArrowDown, gocui.ModNone, moveMCPServerDown)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("mcpServers", 'k', gocui.ModNone, moveMCPServerUp)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("mcpServers", 'j', gocui.ModNone, moveMCPServerDown)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("mcpServers", gocui.KeySpace, gocui.ModNone, toggleMCPServer)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("mcpServers", gocui.KeyEnter, gocui.ModNone, toggleMCPServer)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("mcpServers", gocui.KeyEsc, gocui.ModNone, closeMCPServers)
+	if err != nil {
+		return err
+	}
+
+	// The parameters popup opened with 'p' in the models list: arrows or 'k'/'j' to
+	// move, left/right, 'h'/'l' or '-'/'+' to adjust, 'd' for the model's value,
+	// 'w' to save to config.yml
+	err = g.SetKeybinding("params", gocui.KeyArrowUp, gocui.ModNone, moveParamUp)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", gocui.KeyArrowDown, gocui.ModNone, moveParamDown)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", 'k', gocui.ModNone, moveParamUp)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", 'j', gocui.ModNone, moveParamDown)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", gocui.KeyArrowLeft, gocui.ModNone, decreaseParam)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", gocui.KeyArrowRight, gocui.ModNone, increaseParam)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", 'h', gocui.ModNone, decreaseParam)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", 'l', gocui.ModNone, increaseParam)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", '-', gocui.ModNone, decreaseParam)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", '+', gocui.ModNone, increaseParam)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", 'd', gocui.ModNone, resetParam)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", 'w', gocui.ModNone, writeParams)
+	if err != nil {
+		return err
+	}
+
+	err = g.SetKeybinding("params", gocui.KeyEsc, gocui.ModNone, closeParams)
+	if err != nil {
+		return err
+	}
+
 	err = g.SetKeybinding("", '1', gocui.ModNone, func(g *gocui.Gui, v *gocui.View) error {
 		_, err := setCurrentViewOnTop(g, "providers")
 		g.Cursor = false
@@ -75,5 +75,18 @@ func layout(g *gocui.Gui) error {
 		}
 		v.Title = "Command"
 	}
-	return nil
+
+	if err := layoutAttachments(g, maxX, maxY); err != nil {
+		return err
+	}
+	if err := layoutComparison(g, maxX, maxY); err != nil {
+		return err
+	}
+	if err := layoutTree(g, maxX, maxY); err != nil {
+		return err
+	}
+	if err := layoutMCPServers(g); err != nil {
+		return err
+	}
+	return layoutParams(g)
 }
@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/jroimartin/gocui"
 	"github.com/sashabaranov/go-openai"
@@ -26,6 +30,22 @@ var (
 	activeConvo      = 0
 	config           *Config
 	currentConvo     *Convos
+
+	// Partial assistant reply while a completion is streaming
+	streaming      bool
+	streamingReply string
+	streamingModel string                         // model writing the partial reply, which changes on fallback
+	regenerating   bool                           // the partial reply replaces the last assistant message
+	pendingTurn    []openai.ChatCompletionMessage // tool calls and results of the reply in progress
+	cancelRequest  context.CancelFunc
+
+	// Last user message that failed to get a reply, kept so it can be resent
+	failedTurn string
+	failedErr  error
+
+	// updateUI queues f to run on the UI thread. Tests replace it to run the
+	// streaming code without a terminal.
+	updateUI = (*gocui.Gui).Update
 )
 
 // Process the input text when Enter is pressed
@@ -37,62 +57,754 @@ func processInput(g *gocui.Gui, v *gocui.View) error {
 		return nil
 	}
 
-	// Add the user message to the chat log (right-aligned)
-	chatLogView, err := g.View("chatLog")
-	if err != nil {
-		return err
+	// Wait for the current reply to finish before sending another message
+	if streaming {
+		return nil
 	}
 
-	// Format user message (right-aligned with padding)
-	addUserMessage(chatLogView, inputText)
-
 	// Clear the input view after processing
 	v.Clear()
 	v.SetCursor(0, 0)
 
-	currentProvider, err := config.GetProviderConfig(providers[activeProvider])
+	// A pasted or dragged-in image path attaches the image
+	if path, ok := droppedImagePath(inputText); ok {
+		if err := attachImage(g, path); err != nil {
+			setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+		}
+		return nil
+	}
+
+	// Lines starting with a slash are commands rather than messages
+	if command := strings.TrimSpace(inputText); strings.HasPrefix(command, "/") {
+		return runCommand(g, command)
+	}
+
+	if editingMessage >= 0 {
+		return resendEditedMessage(g, inputText)
+	}
+
+	if len(compareTargets) > 0 {
+		return sendComparison(g, inputText)
+	}
+	activeComparison = nil
+
+	return sendMessage(g, inputText)
+}
+
+// Resend the last user message that failed to get a reply
+func resendFailedTurn(g *gocui.Gui, v *gocui.View) error {
+	if streaming || failedTurn == "" {
+		return nil
+	}
+	return sendMessage(g, failedTurn)
+}
+
+// sendMessage adds a user message to the conversation and streams the reply
+func sendMessage(g *gocui.Gui, inputText string) error {
+	// A new message replaces any previously failed one
+	clearFailedTurn()
+
+	// Attached files and images go out with this message
+	attachments := pendingAttachments
+	pendingAttachments = nil
+
+	// Routing rules or an @model prefix may send this turn to another model
+	route, text := routeTurn(inputText, attachments)
+	route.model = currentConvo.Params.apply(route.model)
+
+	currentConvo.AddChatMessage(userMessage(text, attachments))
+	if err := startCompletion(g, route, false); err != nil {
+		currentConvo.RemoveLastMessage()
+		pendingAttachments = attachments
+		reportFailedTurn(g, inputText, err)
+	}
+	return nil
+}
+
+// startCompletion streams a reply from the route's provider and model to the current
+// history. With regenerate set, the reply becomes a new version of the last
+// assistant message.
+func startCompletion(g *gocui.Gui, route turnRoute, regenerate bool) error {
+	chatLogView, err := g.View("chatLog")
+	if err != nil {
+		return err
+	}
+
+	model := route.model
+	currentProvider, err := config.GetProviderConfig(route.provider)
+	if err != nil {
+		return fmt.Errorf("couldn't get provider config: %w", err)
+	}
+
+	provider, err := newProvider(route.provider, *currentProvider)
 	if err != nil {
-		log.Fatalf("couldnt get api-key: %v\n", err)
+		return err
+	}
+
+	history := withoutReasoning(currentConvo.ChatHistory)
+	if regenerate {
+		history = history[:len(history)-1]
 	}
 
-	var client *openai.Client
-	if providers[activeProvider] != "openai" {
-		clientConfig := openai.DefaultConfig(currentProvider.APIKey)
-		clientConfig.BaseURL = currentProvider.Endpoint
-		client = openai.NewClientWithConfig(clientConfig)
+	job := completionJob{
+		providerName: route.provider,
+		provider:     provider,
+		request: ChatRequest{
+			Model:       model.Name,
+			Temperature: model.Temperature,
+			Sampling:    model.SamplingParams,
+			Messages:    history,
+			Tools:       model.requestTools(),
+
+			ResponseFormat: model.responseFormat(),
+		},
+		model:      model,
+		policy:     currentProvider.retryPolicy(),
+		summary:    currentConvo.Summary,
+		regenerate: regenerate,
+		fallbacks:  fallbackChain(route.provider, model.Name),
+		params:     currentConvo.Params,
+		route:      route.reason,
+		input:      route.input,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelRequest = cancel
+	regenerating = regenerate
+	streaming = true
+	streamingReply = ""
+	streamingModel = model.Name
+	renderStreamingReply(chatLogView)
+	if job.route != "" {
+		setStatus(g, job.routeStatus()+" · "+model.Name+" is typing... (Esc to cancel)")
 	} else {
-		client = openai.NewClient(currentProvider.APIKey)
+		setStatus(g, model.Name+" is typing... (Esc to cancel)")
 	}
-	context := context.Background()
-	currentConvo.AddMessage(openai.ChatMessageRoleUser, inputText)
 
-	go func() {
-		request := openai.ChatCompletionRequest{
-			Model:       models[activeModel].Name,
-			Temperature: models[activeModel].Temperature,
-			Messages:    currentConvo.ChatHistory,
+	go streamResponse(ctx, g, job)
+
+	return nil
+}
+
+// Cancel the in-flight completion, keeping whatever text has arrived,
+// or the message edit in progress
+func cancelCompletion(g *gocui.Gui, v *gocui.View) error {
+	if cancelRequest != nil {
+		cancelRequest()
+		return nil
+	}
+	if activeComparison != nil {
+		dismissComparison(g)
+		setStatus(g, "")
+		return nil
+	}
+	if editingMessage >= 0 || selectedMessage >= 0 {
+		return cancelEdit(g)
+	}
+	return nil
+}
+
+// completionJob is everything a streamed completion needs once it leaves the UI thread
+type completionJob struct {
+	providerName string
+	provider     Provider
+	request      ChatRequest
+	model        ModelConfig
+	policy       retryPolicy
+	summary      *ContextSummary
+	regenerate   bool
+
+	// Backends to try in turn if this one fails, and the conversation's
+	// parameters to use with them
+	fallbacks []string
+	params    *SamplingOverride
+
+	// Why the turn went to this backend rather than the active model, if it did
+	route string
+	// The input as typed, to offer for resending if the turn fails
+	input string
+}
+
+// streamResponse reads the completion stream and renders the reply as it arrives,
+// retrying rate limits and transient failures that happen before any text arrives
+func streamResponse(ctx context.Context, g *gocui.Gui, job completionJob) {
+	var reply strings.Builder
+	var response ChatResponse
+	var streamErr error
+	var total TokenUsage
+	var totalCost float64
+	usageEstimated := false
+	// Usage of every request made for the reply, for the usage ledger
+	var spent []MessageMeta
+	interrupted := false
+
+	// Tool call and tool result messages that lead up to the reply, and the
+	// backend that asked for each
+	var turn []openai.ChatCompletionMessage
+	var turnMeta []MessageMeta
+
+	// Keep the request inside the model's context window
+	fit := fitContext(ctx, job.provider, job.model, job.request.Messages, job.summary)
+	request := job.request
+	request.Messages = fit.Messages
+	typingStatus := request.Model + " is typing... (Esc to cancel)"
+	if job.route != "" {
+		typingStatus = job.routeStatus() + " · " + typingStatus
+	}
+	if fit.Dropped > 0 {
+		typingStatus += fmt.Sprintf(" [context: %d older messages left out]", fit.Dropped)
+	}
+	if fit.Err != nil {
+		typingStatus += " [" + fit.Err.Error() + "]"
+	}
+	updateUI(g, func(g *gocui.Gui) error {
+		setStatus(g, typingStatus)
+		return nil
+	})
+
+	start := time.Now()
+	fellBack := false
+	ctx, hint := withRetryHint(ctx)
+	for round := 1; ; round++ {
+		reply.Reset()
+		for attempt := 0; ; attempt++ {
+			response, streamErr = streamAttempt(ctx, job.provider, request, &reply, func(partial string) {
+				updateUI(g, func(g *gocui.Gui) error {
+					chatLogView, err := g.View("chatLog")
+					if err != nil {
+						return err
+					}
+					streamingReply = partial
+					renderStreamingReply(chatLogView)
+					return nil
+				})
+			})
+			if streamErr == nil || reply.Len() > 0 || ctx.Err() != nil {
+				break
+			}
+			// A failed attempt is still written to the ledger if the provider billed it
+			if response.Usage != nil {
+				failedMeta := replyMeta(job.model, request, "", response.Usage)
+				failedMeta.Provider, failedMeta.Model = job.providerName, job.model.Name
+				spent = append(spent, failedMeta)
+				totalCost += failedMeta.Cost
+			}
+			// A provider asking to wait longer than max_retry_delay counts as out of retries
+			delay, retry := backoffDelay(attempt, job.policy.MaxDelay, hint.take())
+			if attempt >= job.policy.MaxRetries || !shouldRetry(streamErr) || !retry {
+				// Retries are used up, so move on to the next backend of the fallback chain
+				failed := job.providerName + "/" + job.model.Name
+				if !job.fallBack(&request) {
+					break
+				}
+				attempt = -1
+				fellBack = true
+
+				// The next backend may have a smaller context window, so the history
+				// and the tool calls of this turn are fitted again
+				history := append(append([]openai.ChatCompletionMessage{}, job.request.Messages...), turn...)
+				fit = fitContext(ctx, job.provider, job.model, history, job.summary)
+				request.Messages = fit.Messages
+				typingStatus = request.Model + " is typing... (Esc to cancel)"
+				if fit.Dropped > 0 {
+					typingStatus += fmt.Sprintf(" [context: %d older messages left out]", fit.Dropped)
+				}
+				fallbackStatus := fmt.Sprintf("\033[33m%s failed (%s), falling back to %s/%s...\033[0m", failed, describeError(streamErr), job.providerName, request.Model)
+				fallbackModel := request.Model
+				updateUI(g, func(g *gocui.Gui) error {
+					streamingModel = fallbackModel
+					setStatus(g, fallbackStatus)
+					return nil
+				})
+				continue
+			}
+
+			if err := waitForRetry(ctx, g, delay, attempt+1, job.policy.MaxRetries, streamErr); err != nil {
+				break
+			}
+			updateUI(g, func(g *gocui.Gui) error {
+				setStatus(g, typingStatus)
+				return nil
+			})
+		}
+
+		roundMeta := replyMeta(job.model, request, reply.String(), response.Usage)
+		total.PromptTokens += roundMeta.PromptTokens
+		total.CompletionTokens += roundMeta.CompletionTokens
+		totalCost += roundMeta.Cost
+		usageEstimated = usageEstimated || roundMeta.UsageEstimated
+		if streamErr == nil || reply.Len() > 0 || response.Usage != nil {
+			roundMeta.Provider, roundMeta.Model = job.providerName, job.model.Name
+			spent = append(spent, roundMeta)
+		}
+
+		if streamErr != nil || ctx.Err() != nil || len(response.ToolCalls) == 0 {
+			break
+		}
+		if round >= maxToolRounds {
+			response.FinishReason = "tool_limit"
+			break
+		}
+
+		// Run the requested tools and send their results back for the next round
+		_, content := splitReasoning(reply.String())
+		call := openai.ChatCompletionMessage{
+			Role:      openai.ChatMessageRoleAssistant,
+			Content:   content,
+			ToolCalls: response.ToolCalls,
+		}
+		turn = append(turn, call)
+		turnMeta = append(turnMeta, job.backend())
+		// The round's text is part of the turn now, so a cancelled tool call must
+		// not add it again as the reply
+		reply.Reset()
+		showPendingTurn(g, turn)
+
+		results, err := runToolCalls(ctx, g, response.ToolCalls)
+		turn = append(turn, results...)
+		for range results {
+			turnMeta = append(turnMeta, job.backend())
 		}
-		response, err := client.CreateChatCompletion(context, request)
+		showPendingTurn(g, turn)
 		if err != nil {
-			log.Fatalf("ChatCompletionStream error: %v\n", err)
-			return
+			streamErr = err
+			break
 		}
 
-		g.Update(func(g *gocui.Gui) error {
-			addAIResponse(chatLogView, response.Choices[0].Message.Content)
+		request.Messages = append(append([]openai.ChatCompletionMessage{}, request.Messages...), call)
+		request.Messages = append(request.Messages, results...)
+		updateUI(g, func(g *gocui.Gui) error {
+			setStatus(g, typingStatus)
 			return nil
 		})
-	}()
+	}
 
-	return nil
+	// Errors caused by cancelling the request are not failures
+	if streamErr != nil && ctx.Err() != nil {
+		streamErr = nil
+		interrupted = true
+	}
+
+	reasoning, final := splitReasoning(reply.String())
+	meta := MessageMeta{
+		Provider:         job.providerName,
+		Model:            job.model.Name,
+		Reasoning:        reasoning,
+		Interrupted:      interrupted,
+		StopReason:       response.FinishReason,
+		PromptTokens:     total.PromptTokens,
+		CompletionTokens: total.CompletionTokens,
+		UsageEstimated:   usageEstimated,
+		LatencyMs:        time.Since(start).Milliseconds(),
+		Cost:             totalCost,
+	}
+	if request.ResponseFormat != nil && final != "" && !interrupted {
+		meta.Format = "json"
+		meta.JSONError = checkJSONReply(final, request.ResponseFormat)
+	}
+
+	updateUI(g, func(g *gocui.Gui) error {
+		chatLogView, err := g.View("chatLog")
+		if err != nil {
+			return err
+		}
+		streaming = false
+		streamingReply = ""
+		pendingTurn = nil
+		regenerating = false
+		cancelRequest = nil
+		currentConvo.Summary = fit.Summary
+		recordTurnUsage(spent, meta.LatencyMs)
+
+		// Tool calls already ran, so they are kept even if the reply fails
+		if len(turn) > 0 {
+			if job.regenerate {
+				currentConvo.ForkAt(len(currentConvo.ChatHistory) - 1)
+			}
+			for i, msg := range turn {
+				currentConvo.AddChatMessage(msg, turnMeta[i])
+			}
+		}
+
+		if streamErr != nil && len(turn) > 0 {
+			renderChatLog(chatLogView)
+			setStatus(g, "\033[31m"+describeError(streamErr)+"\033[0m")
+			if err := saveCurrentConversation(); err != nil {
+				log.Printf("Failed to save conversation: %v", err)
+			}
+			return nil
+		}
+		if streamErr != nil && job.regenerate {
+			// A failed regeneration leaves the previous reply in place
+			renderChatLog(chatLogView)
+			setStatus(g, "\033[31m"+describeError(streamErr)+"\033[0m (Ctrl+G to try again)")
+			return nil
+		}
+		if streamErr != nil {
+			// Take the unanswered user message back out of the history so it can be
+			// resent as typed, together with its attachments
+			last := len(currentConvo.ChatHistory) - 1
+			failed, failedMeta := currentConvo.ChatHistory[last], currentConvo.MetaAt(last)
+			pendingAttachments = messageAttachments(failed, failedMeta)
+			currentConvo.RemoveLastMessage()
+			input := job.input
+			if input == "" {
+				input = messageInput(failed, failedMeta)
+			}
+			reportFailedTurn(g, input, streamErr)
+			return nil
+		}
+
+		if job.regenerate && len(turn) == 0 {
+			currentConvo.ForkAt(len(currentConvo.ChatHistory) - 1)
+		}
+		renderChatLog(chatLogView)
+		if interrupted {
+			setStatus(g, "Request cancelled")
+			if final == "" {
+				if err := saveCurrentConversation(); err != nil {
+					log.Printf("Failed to save conversation: %v", err)
+				}
+				return nil
+			}
+		} else {
+			setStatus(g, "")
+		}
+		addAIResponse(chatLogView, final, meta)
+
+		if fellBack {
+			setStatus(g, "\033[33mAnswered by fallback "+meta.Provider+"/"+meta.Model+"\033[0m")
+		} else if job.route != "" {
+			setStatus(g, job.routeStatus())
+		}
+		return nil
+	})
+}
+
+// showPendingTurn shows the tool calls and results of the reply in progress
+func showPendingTurn(g *gocui.Gui, turn []openai.ChatCompletionMessage) {
+	pending := append([]openai.ChatCompletionMessage{}, turn...)
+	updateUI(g, func(g *gocui.Gui) error {
+		chatLogView, err := g.View("chatLog")
+		if err != nil {
+			return err
+		}
+		pendingTurn = pending
+		streamingReply = ""
+		renderStreamingReply(chatLogView)
+		return nil
+	})
+}
+
+// replyMeta returns the token usage and cost of a reply. Usage the provider did
+// not report is estimated from the request and the reply text.
+func replyMeta(model ModelConfig, request ChatRequest, reply string, usage *TokenUsage) MessageMeta {
+	var meta MessageMeta
+	if usage != nil {
+		meta.PromptTokens = usage.PromptTokens
+		meta.CompletionTokens = usage.CompletionTokens
+	} else {
+		meta.PromptTokens = estimateHistoryTokens(request.Messages)
+		meta.CompletionTokens = estimateTokens(reply)
+		meta.UsageEstimated = true
+	}
+	meta.Cost = model.Pricing.cost(meta.PromptTokens, meta.CompletionTokens)
+	return meta
+}
+
+// recordReplyUsage appends a reply's usage to the usage ledger
+func recordReplyUsage(providerName, model string, meta MessageMeta) {
+	err := RecordUsage(UsageRecord{
+		Time:             time.Now(),
+		Provider:         providerName,
+		Model:            model,
+		Conversation:     currentConvo.Title,
+		PromptTokens:     meta.PromptTokens,
+		CompletionTokens: meta.CompletionTokens,
+		Estimated:        meta.UsageEstimated,
+		Cost:             meta.Cost,
+		LatencyMs:        meta.LatencyMs,
+	})
+	if err != nil {
+		log.Printf("Failed to record usage: %v", err)
+	}
+}
+
+// recordTurnUsage writes the usage of the requests made for a reply to the usage
+// ledger, including failed ones, with one entry per backend used in a row
+func recordTurnUsage(spent []MessageMeta, latencyMs int64) {
+	var merged []MessageMeta
+	for _, used := range spent {
+		last := len(merged) - 1
+		if last >= 0 && merged[last].Provider == used.Provider && merged[last].Model == used.Model {
+			merged[last].PromptTokens += used.PromptTokens
+			merged[last].CompletionTokens += used.CompletionTokens
+			merged[last].Cost += used.Cost
+			merged[last].UsageEstimated = merged[last].UsageEstimated || used.UsageEstimated
+			continue
+		}
+		merged = append(merged, used)
+	}
+
+	for i, used := range merged {
+		if i == len(merged)-1 {
+			used.LatencyMs = latencyMs
+		}
+		recordReplyUsage(used.Provider, used.Model, used)
+	}
+}
+
+// streamAttempt makes a single streaming request, appending the deltas to reply
+// and passing the reply so far to onDelta. It returns the complete reply with any
+// tool calls, the reason the provider gave for finishing and the token usage it
+// reported.
+func streamAttempt(ctx context.Context, provider Provider, request ChatRequest, reply *strings.Builder, onDelta func(partial string)) (ChatResponse, error) {
+	var response ChatResponse
+	stream, err := provider.Stream(ctx, request)
+	if err != nil {
+		return response, err
+	}
+	defer stream.Close()
+
+	// Reasoning sent apart from the content is written to the reply as a <think>
+	// block, so it is split out the same way as reasoning inside the content
+	thinking := false
+
+	for {
+		delta, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			response.Content = reply.String()
+			return response, nil
+		}
+		if err != nil {
+			response.Content = reply.String()
+			return response, err
+		}
+		if delta.FinishReason != "" {
+			response.FinishReason = delta.FinishReason
+		}
+		if delta.Usage != nil {
+			response.Usage = delta.Usage
+		}
+		response.ToolCalls = mergeToolCalls(response.ToolCalls, delta.ToolCalls)
+		if delta.Reasoning != "" {
+			if !thinking {
+				reply.WriteString(thinkOpen)
+				thinking = true
+			}
+			reply.WriteString(delta.Reasoning)
+			onDelta(reply.String())
+		}
+		if delta.Content == "" {
+			continue
+		}
+
+		if thinking {
+			reply.WriteString(thinkClose)
+			thinking = false
+		}
+		reply.WriteString(delta.Content)
+		onDelta(reply.String())
+	}
+}
+
+// resetChatState forgets per-conversation UI state when switching conversations
+func resetChatState() {
+	activeComparison = nil
+	clearFailedTurn()
+	clearMessageSelection()
+}
+
+// clearFailedTurn forgets the last failed user message
+func clearFailedTurn() {
+	failedTurn = ""
+	failedErr = nil
+}
+
+// reportFailedTurn keeps a user message that got no reply and shows the error inline
+func reportFailedTurn(g *gocui.Gui, inputText string, err error) {
+	failedTurn = inputText
+	failedErr = err
+
+	if chatLogView, viewErr := g.View("chatLog"); viewErr == nil {
+		renderChatLog(chatLogView)
+	}
+	setStatus(g, "\033[31m"+describeError(err)+"\033[0m (Ctrl+R to resend)")
+}
+
+// Redraw the chat log with the partial reply and a typing indicator
+func renderStreamingReply(v *gocui.View) {
+	count := len(currentConvo.ChatHistory)
+	if regenerating {
+		count--
+	}
+	renderHistory(v, count)
+	for _, msg := range pendingTurn {
+		printChatMessage(v, msg, streamingModel)
+	}
+	reasoning, answer := splitReasoning(streamingReply)
+	printReasoning(v, reasoning)
+	switch {
+	case answer != "":
+		printAIResponse(v, answer+" ▍", streamingModel)
+	case reasoning != "":
+		fmt.Fprintln(v)
+		fmt.Fprintf(v, "  \033[36m%s: thinking...\033[0m\n", streamingModel)
+	default:
+		fmt.Fprintln(v)
+		fmt.Fprintf(v, "  \033[36m%s: typing...\033[0m\n", streamingModel)
+	}
+}
+
+// Redraw the chat log from the current conversation history
+func renderChatLog(v *gocui.View) {
+	renderHistory(v, len(currentConvo.ChatHistory))
+}
+
+// Redraw the chat log from the first count messages of the conversation history
+func renderHistory(v *gocui.View, count int) {
+	v.Clear()
+	updateChatLogTitle(v)
+
+	// Display all messages except the system prompt
+	selectedLine := -1
+	for i, msg := range currentConvo.ChatHistory[:count] {
+		if i == 0 && msg.Role == openai.ChatMessageRoleSystem {
+			continue
+		}
+
+		switch msg.Role {
+		case openai.ChatMessageRoleUser:
+			if i == selectedMessage {
+				selectedLine = len(v.BufferLines())
+				printUserMessage(v, messageInput(msg, currentConvo.MetaAt(i)), "1;33")
+			} else {
+				addUserMessage(v, messageInput(msg, currentConvo.MetaAt(i)))
+			}
+			printAttachments(v, msg, currentConvo.MetaAt(i))
+		case openai.ChatMessageRoleAssistant, openai.ChatMessageRoleTool:
+			if len(msg.ToolCalls) == 0 && msg.Role == openai.ChatMessageRoleAssistant {
+				printReply(v, msg.Content, currentConvo.MetaAt(i))
+			} else {
+				printChatMessage(v, msg, replyModel(currentConvo.MetaAt(i)))
+			}
+			printMessageMeta(v, currentConvo.MetaAt(i))
+		}
+		printBranchPosition(v, i)
+	}
+
+	if failedTurn != "" {
+		addUserMessage(v, failedTurn)
+		fmt.Fprintln(v)
+		fmt.Fprintf(v, "  \033[31mError: %s\033[0m\n", describeError(failedErr))
+		fmt.Fprintf(v, "  \033[2mPress Ctrl+R to resend\033[0m\n")
+	}
+
+	// Keep the selected message in view instead of following the bottom
+	if selectedLine >= 0 {
+		v.Autoscroll = false
+		v.SetOrigin(0, max(selectedLine-2, 0))
+	}
+}
+
+// Show the conversation's running token and cost totals in the chat log title
+func updateChatLogTitle(v *gocui.View) {
+	v.Title = "[4]-Chat Log"
+	if usage := currentConvo.Usage; usage.PromptTokens > 0 || usage.CompletionTokens > 0 {
+		v.Title += " (" + formatUsage(usage.PromptTokens, usage.CompletionTokens, usage.Cost) + ")"
+	}
+}
+
+// Print an assistant or tool message from the named model. Tool calls and
+// results are shown as short dimmed lines.
+func printChatMessage(v *gocui.View, msg openai.ChatCompletionMessage, model string) {
+	if msg.Role == openai.ChatMessageRoleTool {
+		lines := strings.Split(strings.TrimRight(msg.Content, "\n"), "\n")
+		summary := truncateLine(lines[0], 60)
+		if len(lines) > 1 {
+			summary += fmt.Sprintf(" (%d lines)", len(lines))
+		}
+		fmt.Fprintf(v, "  \033[2m← %s: %s\033[0m\n", msg.Name, summary)
+		return
+	}
+
+	if msg.Content != "" || len(msg.ToolCalls) == 0 {
+		printAIResponse(v, msg.Content, model)
+	}
+	if len(msg.ToolCalls) > 0 {
+		fmt.Fprintln(v)
+	}
+	for _, call := range msg.ToolCalls {
+		fmt.Fprintf(v, "  \033[35m→ %s %s\033[0m\n", call.Function.Name, truncateLine(call.Function.Arguments, 80))
+	}
+}
+
+// truncateLine shortens text to at most width runes on a single line
+func truncateLine(text string, width int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > width {
+		return string(runes[:width]) + "…"
+	}
+	return text
+}
+
+// Print the metadata notes for a message (dimmed, below the message)
+func printMessageMeta(v *gocui.View, meta MessageMeta) {
+	if meta.Interrupted {
+		fmt.Fprintf(v, "  \033[2m[interrupted]\033[0m\n")
+	}
+	if meta.StopReason == "length" || meta.StopReason == "max_tokens" {
+		fmt.Fprintf(v, "  \033[2m[truncated: max tokens reached]\033[0m\n")
+	}
+	if meta.JSONError != "" {
+		fmt.Fprintf(v, "  \033[31m[invalid JSON reply: %s]\033[0m\n", meta.JSONError)
+	}
+	if meta.Provider != "" && (meta.Provider != currentConvo.Provider || meta.Model != currentConvo.Model) {
+		fmt.Fprintf(v, "  \033[33m[answered by %s/%s]\033[0m\n", meta.Provider, meta.Model)
+	}
+	if meta.StopReason == "tool_limit" {
+		fmt.Fprintf(v, "  \033[2m[stopped: too many tool calls]\033[0m\n")
+	}
+	if meta.PromptTokens > 0 || meta.CompletionTokens > 0 {
+		usage := formatUsage(meta.PromptTokens, meta.CompletionTokens, meta.Cost)
+		if meta.UsageEstimated {
+			usage = "~" + usage
+		}
+		latency := time.Duration(meta.LatencyMs) * time.Millisecond
+		fmt.Fprintf(v, "  \033[2m%s · %s\033[0m\n", usage, latency.Round(100*time.Millisecond))
+	}
+}
+
+// Print which of its sibling branches a message is, if it has any
+func printBranchPosition(v *gocui.View, index int) {
+	position, total := currentConvo.BranchPosition(index)
+	if total < 2 {
+		return
+	}
+	fmt.Fprintf(v, "  \033[2m[branch %d/%d, [ and ] to switch]\033[0m\n", position, total)
+}
+
+// Show a status message in the command bar
+func setStatus(g *gocui.Gui, message string) {
+	v, err := g.View("commandBar")
+	if err != nil {
+		return
+	}
+	v.Clear()
+	fmt.Fprint(v, message)
 }
 
 // Add a user message to the chat log (right-aligned)
 func addUserMessage(v *gocui.View, message string) {
+	printUserMessage(v, message, "32")
+}
+
+// Print a user message to the chat log (right-aligned) in the given ANSI color
+func printUserMessage(v *gocui.View, message, color string) {
 	width, _ := v.Size()
 
 	// Format the message with word wrapping
-	formattedMsg := formatMessage(message, width-10, true) // -10 for padding
+	formattedMsg := formatMessage(message, width-10, "You") // -10 for padding
 
 	// Add a separator line
 	fmt.Fprintln(v)
@@ -100,19 +812,34 @@ func addUserMessage(v *gocui.View, message string) {
 	// Print the formatted message lines (right-aligned)
 	for _, line := range strings.Split(formattedMsg, "\n") {
 		padding := max(width-len(line)-2, 0)
-		fmt.Fprintf(v, "%s\033[32m%s\033[0m\n", strings.Repeat(" ", padding), line)
+		fmt.Fprintf(v, "%s\033[%sm%s\033[0m\n", strings.Repeat(" ", padding), color, line)
 	}
 
 	// Auto-scroll to the bottom
 	v.Autoscroll = true
 }
 
-// Add an AI response to the chat log (left-aligned)
-func addAIResponse(v *gocui.View, message string) {
+// Add an AI response to the chat log and persist it to the conversation
+func addAIResponse(v *gocui.View, message string, meta MessageMeta) {
+	printReply(v, message, meta)
+	printMessageMeta(v, meta)
+
+	// add AI response back to the chat history
+	currentConvo.AddMessageWithMeta(openai.ChatMessageRoleAssistant, message, meta)
+	updateChatLogTitle(v)
+
+	// Save the conversation after each AI response
+	if err := saveCurrentConversation(); err != nil {
+		log.Printf("Failed to save conversation: %v", err)
+	}
+}
+
+// Print an AI response from the named model to the chat log (left-aligned)
+func printAIResponse(v *gocui.View, message, model string) {
 	width, _ := v.Size()
 
 	// Format the message with word wrapping
-	formattedMsg := formatMessage(message, width-10, false) // -10 for padding
+	formattedMsg := formatMessage(message, width-10, model) // -10 for padding
 
 	// Add a separator line
 	fmt.Fprintln(v)
@@ -124,27 +851,19 @@ func addAIResponse(v *gocui.View, message string) {
 
 	// Auto-scroll to the bottom
 	v.Autoscroll = true
-
-	// add AI response back to the chat history
-	currentConvo.AddMessage(openai.ChatMessageRoleAssistant, message)
-
-	// Save the conversation after each AI response
-	if err := saveCurrentConversation(); err != nil {
-		log.Printf("Failed to save conversation: %v", err)
-	}
 }
 
-// Format a message with word wrapping
-func formatMessage(message string, maxWidth int, isUser bool) string {
+// Format a message with word wrapping, prefixed with the speaker's name if given
+func formatMessage(message string, maxWidth int, speaker string) string {
 	words := strings.Fields(message)
 	if len(words) == 0 {
 		return ""
 	}
 
 	// Add a prefix to indicate who's speaking
-	prefix := models[activeModel].Name + ": "
-	if isUser {
-		prefix = "You: "
+	prefix := ""
+	if speaker != "" {
+		prefix = speaker + ": "
 	}
 
 	var lines []string
@@ -322,6 +1041,14 @@ func createNewConversation() {
 }
 
 func main() {
+	// "atlas usage [provider|model|day]" prints recorded usage instead of starting the UI
+	if len(os.Args) > 1 && os.Args[1] == "usage" {
+		if err := runUsageCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("Failed to summarize usage: %v", err)
+		}
+		return
+	}
+
 	// Load config from default path
 	var err error
 	config, err = LoadConfig()
@@ -332,18 +1059,31 @@ func main() {
 	// Get providers from config
 	providers = config.GetAllProviders()
 
-	// Get models for the selected provider from config
-	models, err = config.GetModelsForProvider(providers[selectedProvider])
-	if err != nil {
-		log.Fatalf("Failed to get models: %v", err)
-	}
-
 	// ensures that openai is always the first selected provider
 	for i, provider := range providers {
 		if provider == "openai" {
 			activeProvider = i
 		}
 	}
+	selectedProvider = activeProvider
+
+	// Get models for the active provider from config and its discovered models cache.
+	// Discovery only blocks startup when there is nothing else to show.
+	var staleModels bool
+	models, staleModels, err = loadProviderModels(providers[activeProvider])
+	if len(models) == 0 && staleModels && err == nil {
+		models, err = discoverProviderModels(providers[activeProvider])
+		staleModels = false
+	}
+	if len(models) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no models configured for provider %s", providers[activeProvider])
+		}
+		log.Fatalf("Failed to get models: %v", err)
+	}
+	if err != nil {
+		log.Printf("Failed to discover models: %v", err)
+	}
 	config.ActiveProvider = providers[activeProvider]
 	config.ActiveModel = models[activeModel].Name
 	active = 4 // sets active pane to input view
@@ -361,6 +1101,34 @@ func main() {
 	}
 	defer g.Close()
 
+	// Refresh a stale discovered models cache without holding up the UI
+	if staleModels {
+		refreshProviderModels(g, providers[activeProvider], nil)
+	}
+
+	// Show config problems that were not fatal, like an unreadable schema file
+	if len(config.warnings) > 0 {
+		g.Update(func(g *gocui.Gui) error {
+			setStatus(g, "\033[33m"+strings.Join(config.warnings, "; ")+"\033[0m")
+			return nil
+		})
+	}
+
+	// Start the configured MCP servers in the background; their tools become
+	// available as soon as they are up
+	loadMCPServers(config.MCPServers)
+	defer stopMCPServers()
+	go func() {
+		errs := startMCPServers()
+		if len(errs) == 0 {
+			return
+		}
+		g.Update(func(g *gocui.Gui) error {
+			setStatus(g, "\033[31m"+errs[0].Error()+"\033[0m (/mcp to manage servers)")
+			return nil
+		})
+	}()
+
 	g.Highlight = true
 	g.Cursor = true
 	g.Mouse = true
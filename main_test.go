@@ -0,0 +1,257 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// mockSession is a conversation with the mock provider and a headless UI whose
+// updates the test runs itself
+type mockSession struct {
+	t      *testing.T
+	g      *gocui.Gui
+	events chan func(*gocui.Gui) error
+}
+
+// newMockSession points the app at a mock provider with the given settings and a
+// fresh conversation, keeping saved files in a temporary home directory
+func newMockSession(t *testing.T, mock MockConfig) *mockSession {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	savedConfig, savedProviders, savedModels, savedConvo, savedUpdate := config, providers, models, currentConvo, updateUI
+	t.Cleanup(func() {
+		config, providers, models, currentConvo, updateUI = savedConfig, savedProviders, savedModels, savedConvo, savedUpdate
+		activeProvider, activeModel = 0, 0
+		streaming, cancelRequest = false, nil
+		clearFailedTurn()
+	})
+
+	if mock.Delay == 0 {
+		mock.Delay = time.Millisecond
+	}
+	config = &Config{Providers: map[string]ProviderConfig{
+		"mock": {
+			Type:          "mock",
+			MaxRetryDelay: 10 * time.Millisecond,
+			Mock:          &mock,
+			Models:        []ModelConfig{{Name: "echo", Tools: []string{"list_dir"}}},
+		},
+	}}
+	providers = []string{"mock"}
+	models = config.Providers["mock"].Models
+	activeProvider, activeModel = 0, 0
+	currentConvo = NewConvos("Test", "mock", "echo")
+	currentConvo.AddMessage(openai.ChatMessageRoleSystem, "Be brief.")
+
+	mockMu.Lock()
+	delete(mockRequests, "mock")
+	mockMu.Unlock()
+
+	g := &gocui.Gui{}
+	for _, name := range []string{"chatLog", "commandBar"} {
+		if _, err := g.SetView(name, 0, 0, 80, 24); err != nil && err != gocui.ErrUnknownView {
+			t.Fatal(err)
+		}
+	}
+
+	events := make(chan func(*gocui.Gui) error, 1024)
+	updateUI = func(g *gocui.Gui, f func(*gocui.Gui) error) { events <- f }
+	return &mockSession{t: t, g: g, events: events}
+}
+
+// step runs the next UI update
+func (s *mockSession) step() {
+	s.t.Helper()
+	select {
+	case f := <-s.events:
+		if err := f(s.g); err != nil {
+			s.t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("timed out waiting for the reply")
+	}
+}
+
+// send sends a message and runs UI updates until the reply has finished
+func (s *mockSession) send(input string) {
+	s.t.Helper()
+	if err := sendMessage(s.g, input); err != nil {
+		s.t.Fatal(err)
+	}
+	for streaming {
+		s.step()
+	}
+}
+
+// lastReply returns the last message of the conversation and its metadata
+func lastReply() (openai.ChatCompletionMessage, MessageMeta) {
+	last := len(currentConvo.ChatHistory) - 1
+	return currentConvo.ChatHistory[last], currentConvo.MetaAt(last)
+}
+
+func TestSendMessageEcho(t *testing.T) {
+	session := newMockSession(t, MockConfig{})
+	session.send("hi there")
+
+	reply, meta := lastReply()
+	if reply.Role != openai.ChatMessageRoleAssistant || reply.Content != "You said: hi there" {
+		t.Fatalf("reply = %s %q, want the echo", reply.Role, reply.Content)
+	}
+	if meta.Provider != "mock" || meta.Model != "echo" || meta.CompletionTokens == 0 {
+		t.Errorf("meta = %+v, want the mock's backend and usage", meta)
+	}
+	if len(currentConvo.ChatHistory) != 3 {
+		t.Errorf("history has %d messages, want system, user and assistant", len(currentConvo.ChatHistory))
+	}
+}
+
+func TestSendMessageScripted(t *testing.T) {
+	script, err := filepath.Abs("testdata/mock_script.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := newMockSession(t, MockConfig{Script: script})
+
+	session.send("hello")
+	if reply, _ := lastReply(); reply.Content != "Hello! I'm the offline mock model." {
+		t.Errorf("reply = %q, want the scripted greeting", reply.Content)
+	}
+
+	session.send("think about it")
+	reply, meta := lastReply()
+	if reply.Content != "Here's the answer after thinking it over." || !strings.Contains(meta.Reasoning, "reasoning") {
+		t.Errorf("reply, reasoning = %q, %q; want them apart", reply.Content, meta.Reasoning)
+	}
+}
+
+func TestSendMessageScriptedToolCall(t *testing.T) {
+	script, err := filepath.Abs("testdata/mock_script.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := newMockSession(t, MockConfig{Script: script})
+	session.send("list the files")
+
+	history := currentConvo.ChatHistory
+	if len(history) != 5 {
+		t.Fatalf("history has %d messages, want system, user, tool call, tool result and reply", len(history))
+	}
+	call, result, reply := history[2], history[3], history[4]
+	if len(call.ToolCalls) != 1 || call.ToolCalls[0].Function.Name != "list_dir" {
+		t.Errorf("tool call = %+v, want list_dir", call.ToolCalls)
+	}
+	if result.Role != openai.ChatMessageRoleTool || result.ToolCallID != call.ToolCalls[0].ID || !strings.Contains(result.Content, "go.mod") {
+		t.Errorf("tool result = %+v, want the directory listing", result)
+	}
+	if reply.Content != "Those are the files in the current directory." {
+		t.Errorf("reply = %q, want the scripted reply after the tool call", reply.Content)
+	}
+}
+
+func TestSendMessageRetriesInjectedFailure(t *testing.T) {
+	session := newMockSession(t, MockConfig{FailEvery: 2})
+	mockMu.Lock()
+	mockRequests["mock"] = 1 // the next request is the second, which fails with a 503
+	mockMu.Unlock()
+
+	session.send("hi")
+
+	if reply, _ := lastReply(); reply.Content != "You said: hi" {
+		t.Errorf("reply = %q, want the echo from the retry", reply.Content)
+	}
+	mockMu.Lock()
+	defer mockMu.Unlock()
+	if mockRequests["mock"] != 3 {
+		t.Errorf("mock got %d requests, want the failed one and a retry", mockRequests["mock"]-1)
+	}
+}
+
+func TestSendMessageFailureKeepsInput(t *testing.T) {
+	script, err := filepath.Abs("testdata/mock_script.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := newMockSession(t, MockConfig{Script: script})
+	session.send("@echo bad request")
+
+	if failedTurn != "@echo bad request" || failedErr == nil {
+		t.Errorf("failed turn = %q (%v), want the input as typed", failedTurn, failedErr)
+	}
+	if len(currentConvo.ChatHistory) != 1 {
+		t.Errorf("history has %d messages, want the unanswered message taken out", len(currentConvo.ChatHistory))
+	}
+}
+
+func TestSendMessageCancel(t *testing.T) {
+	session := newMockSession(t, MockConfig{Delay: 20 * time.Millisecond})
+	if err := sendMessage(session.g, "one two three four five six seven eight nine ten"); err != nil {
+		t.Fatal(err)
+	}
+	for streamingReply == "" {
+		session.step()
+	}
+	if err := cancelCompletion(session.g, nil); err != nil {
+		t.Fatal(err)
+	}
+	for streaming {
+		session.step()
+	}
+
+	reply, meta := lastReply()
+	if reply.Role != openai.ChatMessageRoleAssistant || !meta.Interrupted {
+		t.Fatalf("reply = %s %q (interrupted: %t), want the partial reply kept", reply.Role, reply.Content, meta.Interrupted)
+	}
+	full := "You said: one two three four five six seven eight nine ten"
+	if reply.Content == "" || !strings.HasPrefix(full, reply.Content) || len(reply.Content) >= len(full) {
+		t.Errorf("reply = %q, want only the part streamed before cancelling", reply.Content)
+	}
+}
+
+func TestRegenerateCommand(t *testing.T) {
+	session := newMockSession(t, MockConfig{})
+	session.send("hi")
+
+	if err := regenerateCommand(session.g, []string{"temp=3"}); err != nil {
+		t.Fatal(err)
+	}
+	if streaming {
+		t.Fatal("regenerating with temp=3 started a request, want it rejected")
+	}
+
+	if err := regenerateCommand(session.g, []string{"model=echo-2", "temp=0.5"}); err != nil {
+		t.Fatal(err)
+	}
+	for streaming {
+		session.step()
+	}
+	reply, meta := lastReply()
+	if reply.Content != "You said: hi" || meta.Model != "echo-2" {
+		t.Errorf("reply = %q from %s, want the echo regenerated by echo-2", reply.Content, meta.Model)
+	}
+}
+
+func TestSendMessageLabelsRoutedModel(t *testing.T) {
+	session := newMockSession(t, MockConfig{})
+	if err := sendMessage(session.g, "@mock/echo-2 hi"); err != nil {
+		t.Fatal(err)
+	}
+	chatLog, err := session.g.View("chatLog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log := chatLog.Buffer(); !strings.Contains(log, "echo-2: typing...") {
+		t.Errorf("chat log = %q, want the routed model typing", log)
+	}
+	for streaming {
+		session.step()
+	}
+	if log := chatLog.Buffer(); !strings.Contains(log, "echo-2: You said: hi") {
+		t.Errorf("chat log = %q, want the reply labelled with the routed model", log)
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	mcpProtocolVersion = "2024-11-05"
+	// How long starting a server and listing its tools may take
+	mcpStartTimeout = 30 * time.Second
+)
+
+// mcpError is a JSON-RPC error returned by an MCP server
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *mcpError) Error() string {
+	return fmt.Sprintf("MCP error %d: %s", e.Code, e.Message)
+}
+
+// mcpMessage is a JSON-RPC 2.0 request, notification or response
+type mcpMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+// mcpToolInfo is a tool as listed by an MCP server
+type mcpToolInfo struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Annotations struct {
+		ReadOnlyHint bool `json:"readOnlyHint"`
+	} `json:"annotations"`
+}
+
+// mcpClient is a running stdio MCP server and the tools it offers
+type mcpClient struct {
+	name   string
+	config MCPServerConfig
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	tools  []mcpToolInfo
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan mcpMessage
+	done    chan struct{}
+}
+
+// startMCPClient launches the server, performs the initialize handshake and lists its tools
+func startMCPClient(ctx context.Context, name string, config MCPServerConfig) (*mcpClient, error) {
+	if config.Command == "" {
+		return nil, fmt.Errorf("MCP server %s: command is required", name)
+	}
+
+	cmd := exec.Command(config.Command, config.Args...)
+	cmd.Env = os.Environ()
+	for key, value := range config.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %w", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start MCP server %s: %w", name, err)
+	}
+
+	client := &mcpClient{
+		name:    name,
+		config:  config,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[int64]chan mcpMessage{},
+		done:    make(chan struct{}),
+	}
+	go client.readLoop(stdout)
+
+	if err := client.initialize(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("MCP server %s: %w", name, err)
+	}
+	return client, nil
+}
+
+// initialize performs the MCP handshake and fetches the server's tools
+func (c *mcpClient) initialize(ctx context.Context) error {
+	_, err := c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "atlas", "version": "1.0"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+	if err := c.send(mcpMessage{JSONRPC: "2.0", Method: "notifications/initialized"}); err != nil {
+		return err
+	}
+
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		result, err := c.call(ctx, "tools/list", params)
+		if err != nil {
+			return fmt.Errorf("failed to list tools: %w", err)
+		}
+
+		var page struct {
+			Tools      []mcpToolInfo `json:"tools"`
+			NextCursor string        `json:"nextCursor"`
+		}
+		if err := json.Unmarshal(result, &page); err != nil {
+			return fmt.Errorf("failed to decode tools: %w", err)
+		}
+		c.tools = append(c.tools, page.Tools...)
+		if page.NextCursor == "" {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// readLoop hands responses from the server to the calls waiting for them
+func (c *mcpClient) readLoop(stdout io.Reader) {
+	defer close(c.done)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg mcpMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil || msg.ID == nil || msg.Method != "" {
+			// Notifications, server requests and stray output are ignored
+			continue
+		}
+
+		c.mu.Lock()
+		reply, exists := c.pending[*msg.ID]
+		delete(c.pending, *msg.ID)
+		c.mu.Unlock()
+		if exists {
+			reply <- msg
+		}
+	}
+}
+
+// send writes one message to the server's stdin
+func (c *mcpClient) send(msg mcpMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal MCP message: %w", err)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to MCP server %s: %w", c.name, err)
+	}
+	return nil
+}
+
+// call sends a request and waits for its result
+func (c *mcpClient) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	reply := make(chan mcpMessage, 1)
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = reply
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(mcpMessage{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	case <-c.done:
+		return nil, fmt.Errorf("MCP server %s exited", c.name)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// callTool runs one of the server's tools and returns its text output
+func (c *mcpClient) callTool(ctx context.Context, name, arguments string) (string, error) {
+	args := json.RawMessage(arguments)
+	if strings.TrimSpace(arguments) == "" {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		return "", errors.New("invalid arguments: not valid JSON")
+	}
+
+	result, err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args})
+	if err != nil {
+		return "", err
+	}
+
+	var body struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	if err := json.Unmarshal(result, &body); err != nil {
+		return "", fmt.Errorf("failed to decode tool result: %w", err)
+	}
+
+	var parts []string
+	for _, content := range body.Content {
+		if content.Type == "text" {
+			parts = append(parts, content.Text)
+		} else {
+			parts = append(parts, "["+content.Type+" content]")
+		}
+	}
+	output := strings.Join(parts, "\n")
+	if body.IsError {
+		return "", errors.New(output)
+	}
+	return output, nil
+}
+
+// Close stops the server
+func (c *mcpClient) Close() error {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+		c.cmd.Process.Kill()
+	}
+	return c.cmd.Wait()
+}
+
+// mcpServer is a configured MCP server and its state in this session
+type mcpServer struct {
+	name    string
+	config  MCPServerConfig
+	enabled bool
+	client  *mcpClient
+	err     error
+}
+
+var (
+	mcpMu      sync.Mutex
+	mcpServers []*mcpServer
+)
+
+var toolNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// mcpToolName is the name a server's tool is offered to the model under
+func mcpToolName(server, tool string) string {
+	name := toolNameInvalid.ReplaceAllString(server+"__"+tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// loadMCPServers sets up the configured MCP servers in name order without starting them
+func loadMCPServers(configs map[string]MCPServerConfig) {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mcpMu.Lock()
+	defer mcpMu.Unlock()
+	for _, name := range names {
+		mcpServers = append(mcpServers, &mcpServer{name: name, config: configs[name], enabled: !configs[name].Disabled})
+	}
+}
+
+// startMCPServers starts every enabled server that is not running yet and
+// returns the errors of the ones that failed
+func startMCPServers() []error {
+	mcpMu.Lock()
+	var toStart []*mcpServer
+	for _, server := range mcpServers {
+		if server.enabled && server.client == nil {
+			toStart = append(toStart, server)
+		}
+	}
+	mcpMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, server := range toStart {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+			defer cancel()
+			client, err := startMCPClient(ctx, server.name, server.config)
+
+			mcpMu.Lock()
+			server.client, server.err = client, err
+			mcpMu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, server := range toStart {
+		if server.err != nil {
+			errs = append(errs, server.err)
+		}
+	}
+	return errs
+}
+
+// stopMCPServers shuts down every running server
+func stopMCPServers() {
+	mcpMu.Lock()
+	defer mcpMu.Unlock()
+	for _, server := range mcpServers {
+		if server.client != nil {
+			server.client.Close()
+			server.client = nil
+		}
+	}
+}
+
+// mcpToolDefinitions returns the tools of every enabled, running server
+func mcpToolDefinitions() []openai.Tool {
+	mcpMu.Lock()
+	defer mcpMu.Unlock()
+
+	var definitions []openai.Tool
+	for _, server := range mcpServers {
+		if !server.enabled || server.client == nil {
+			continue
+		}
+		for _, info := range server.client.tools {
+			parameters := info.InputSchema
+			if parameters == nil {
+				parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			definitions = append(definitions, openai.Tool{
+				Type: openai.ToolTypeFunction,
+				Function: &openai.FunctionDefinition{
+					Name:        mcpToolName(server.name, info.Name),
+					Description: info.Description,
+					Parameters:  parameters,
+				},
+			})
+		}
+	}
+	return definitions
+}
+
+// findMCPTool returns the tool of an enabled server offered under the given name
+func findMCPTool(name string) (tool, bool) {
+	mcpMu.Lock()
+	defer mcpMu.Unlock()
+
+	for _, server := range mcpServers {
+		if !server.enabled || server.client == nil {
+			continue
+		}
+		for _, info := range server.client.tools {
+			if mcpToolName(server.name, info.Name) != name {
+				continue
+			}
+			client, toolName := server.client, info.Name
+			return tool{
+				definition:  openai.FunctionDefinition{Name: name, Description: info.Description},
+				sideEffects: !server.config.Trusted && !info.Annotations.ReadOnlyHint,
+				run: func(ctx context.Context, arguments string) (string, error) {
+					return client.callTool(ctx, toolName, arguments)
+				},
+			}, true
+		}
+	}
+	return tool{}, false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildFakeMCP builds testdata/fakemcp and returns the path of the binary
+func buildFakeMCP(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go tool not available to build testdata/fakemcp")
+	}
+
+	binary := filepath.Join(t.TempDir(), "fakemcp")
+	if output, err := exec.Command("go", "build", "-o", binary, "./testdata/fakemcp").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build fakemcp: %v\n%s", err, output)
+	}
+	return binary
+}
+
+// startFakeMCP builds testdata/fakemcp and starts a client for it
+func startFakeMCP(t *testing.T) *mcpClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := startMCPClient(ctx, "fake", MCPServerConfig{Command: buildFakeMCP(t)})
+	if err != nil {
+		t.Fatalf("startMCPClient: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestMCPClientListsTools(t *testing.T) {
+	client := startFakeMCP(t)
+
+	names := map[string]bool{}
+	for _, tool := range client.tools {
+		names[tool.Name] = true
+	}
+	if len(client.tools) != 2 || !names["echo"] || !names["add"] {
+		t.Fatalf("tools = %+v, want echo and add", client.tools)
+	}
+	for _, tool := range client.tools {
+		if tool.Name == "echo" && !tool.Annotations.ReadOnlyHint {
+			t.Error("echo should be marked read-only")
+		}
+	}
+}
+
+func TestMCPClientCallsTools(t *testing.T) {
+	client := startFakeMCP(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sum, err := client.callTool(ctx, "add", `{"a": 2, "b": 3}`)
+	if err != nil || sum != "5" {
+		t.Errorf("add = %q, %v; want 5", sum, err)
+	}
+
+	echoed, err := client.callTool(ctx, "echo", `{"text": "hello"}`)
+	if err != nil || echoed != "hello" {
+		t.Errorf("echo = %q, %v; want hello", echoed, err)
+	}
+
+	if _, err := client.callTool(ctx, "missing", ""); err == nil {
+		t.Error("unknown tool should return an error")
+	}
+	if _, err := client.callTool(ctx, "add", "{"); err == nil {
+		t.Error("invalid arguments should return an error")
+	}
+}
+
+func TestModelRequestToolsMCPOptIn(t *testing.T) {
+	loadMCPServers(map[string]MCPServerConfig{"fake": {Command: buildFakeMCP(t)}})
+	t.Cleanup(func() {
+		stopMCPServers()
+		mcpMu.Lock()
+		mcpServers = nil
+		mcpMu.Unlock()
+	})
+	if errs := startMCPServers(); len(errs) > 0 {
+		t.Fatalf("startMCPServers: %v", errs)
+	}
+
+	if tools := (ModelConfig{Tools: []string{"read_file"}}).requestTools(); len(tools) != 1 {
+		t.Errorf("model without mcp got %d tools, want only read_file", len(tools))
+	}
+	tools := (ModelConfig{Tools: []string{"read_file"}, MCP: true}).requestTools()
+	if len(tools) != 3 || tools[1].Function.Name != "fake__echo" {
+		t.Errorf("model with mcp got %d tools, want read_file, fake__echo and fake__add", len(tools))
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+var (
+	showMCPServers    = false // whether the MCP server list is open
+	selectedMCPServer = 0
+)
+
+// mcpCommand handles /mcp, opening the list of MCP servers
+func mcpCommand(g *gocui.Gui, args []string) error {
+	mcpMu.Lock()
+	count := len(mcpServers)
+	mcpMu.Unlock()
+	if count == 0 {
+		setStatus(g, "No MCP servers configured (add them under mcp_servers in config.yml)")
+		return nil
+	}
+
+	showMCPServers = true
+	if err := layoutMCPServers(g); err != nil {
+		return err
+	}
+	g.Cursor = false
+	_, err := setCurrentViewOnTop(g, "mcpServers")
+	return err
+}
+
+// Close the MCP server list
+func closeMCPServers(g *gocui.Gui, v *gocui.View) error {
+	showMCPServers = false
+	if err := layoutMCPServers(g); err != nil {
+		return err
+	}
+	if _, err := setCurrentViewOnTop(g, viewArr[active]); err != nil {
+		return err
+	}
+	g.Cursor = active == 4
+	return nil
+}
+
+// Move the selection up in the MCP server list
+func moveMCPServerUp(g *gocui.Gui, v *gocui.View) error {
+	if selectedMCPServer > 0 {
+		selectedMCPServer--
+	}
+	return nil
+}
+
+// Move the selection down in the MCP server list
+func moveMCPServerDown(g *gocui.Gui, v *gocui.View) error {
+	mcpMu.Lock()
+	defer mcpMu.Unlock()
+	if selectedMCPServer < len(mcpServers)-1 {
+		selectedMCPServer++
+	}
+	return nil
+}
+
+// Enable or disable the selected MCP server. Enabling starts it in the background;
+// disabling stops it and takes its tools away from the model.
+func toggleMCPServer(g *gocui.Gui, v *gocui.View) error {
+	mcpMu.Lock()
+	if selectedMCPServer >= len(mcpServers) {
+		mcpMu.Unlock()
+		return nil
+	}
+	server := mcpServers[selectedMCPServer]
+	server.enabled = !server.enabled
+	server.err = nil
+	client := server.client
+	if !server.enabled {
+		server.client = nil
+	}
+	enabled := server.enabled
+	mcpMu.Unlock()
+
+	if !enabled {
+		if client != nil {
+			client.Close()
+		}
+		setStatus(g, "Disabled MCP server "+server.name)
+		return nil
+	}
+
+	setStatus(g, "Starting MCP server "+server.name+"...")
+	go func() {
+		errs := startMCPServers()
+		g.Update(func(g *gocui.Gui) error {
+			if len(errs) > 0 {
+				setStatus(g, "\033[31m"+errs[0].Error()+"\033[0m")
+				return nil
+			}
+			setStatus(g, "Enabled MCP server "+server.name)
+			return nil
+		})
+	}()
+	return nil
+}
+
+// layoutMCPServers draws the MCP server list over the chat log while it is open
+func layoutMCPServers(g *gocui.Gui) error {
+	if !showMCPServers {
+		if err := g.DeleteView("mcpServers"); err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+		return nil
+	}
+
+	maxX, maxY := g.Size()
+	mcpMu.Lock()
+	defer mcpMu.Unlock()
+
+	v, err := g.SetView("mcpServers", maxX/3, maxY/4, maxX-maxX/6, maxY/4+len(mcpServers)+3)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	v.Title = "MCP Servers (Space to toggle, Esc to close)"
+	v.Clear()
+
+	for i, server := range mcpServers {
+		check := " "
+		if server.enabled {
+			check = "x"
+		}
+
+		var state string
+		switch {
+		case !server.enabled:
+			state = "\033[2mdisabled\033[0m"
+		case server.err != nil:
+			state = "\033[31m" + server.err.Error() + "\033[0m"
+		case server.client == nil:
+			state = "\033[33mstarting...\033[0m"
+		default:
+			names := make([]string, len(server.client.tools))
+			for j, info := range server.client.tools {
+				names[j] = info.Name
+			}
+			state = fmt.Sprintf("\033[32m%d tools\033[0m \033[2m%s\033[0m", len(names), strings.Join(names, ", "))
+		}
+
+		line := fmt.Sprintf("[%s] %s  %s", check, server.name, state)
+		if i == selectedMCPServer {
+			line = "\033[1m> " + line + "\033[0m"
+		} else {
+			line = "  " + line
+		}
+		fmt.Fprintln(v, line)
+	}
+	return nil
+}
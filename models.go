@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jroimartin/gocui"
+)
+
+const (
+	// modelDiscoveryTimeout bounds how long listing a provider's models may take
+	modelDiscoveryTimeout = 5 * time.Second
+	// defaultModelsCacheTTL is how long discovered models are reused before asking again
+	defaultModelsCacheTTL = time.Hour
+)
+
+// modelsCache is the on-disk record of a provider's discovered models
+type modelsCache struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Models    []string  `json:"models"`
+}
+
+// loadProviderModels returns the configured models of a provider merged with its
+// cached discovered models, without any network access. stale reports that
+// discovery is on and the cache is missing or older than models_cache_ttl, so the
+// list should be refreshed with refreshProviderModels.
+func loadProviderModels(name string) (loaded []ModelConfig, stale bool, err error) {
+	configured, err := config.GetModelsForProvider(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	providerConfig, err := config.GetProviderConfig(name)
+	if err != nil {
+		return configured, false, err
+	}
+	if !providerConfig.DiscoverModels {
+		return configured, false, nil
+	}
+
+	ttl := providerConfig.ModelsCacheTTL
+	if ttl <= 0 {
+		ttl = defaultModelsCacheTTL
+	}
+	cached, err := loadModelsCache(name)
+	if err != nil {
+		return configured, true, nil
+	}
+	return mergeModels(configured, cached.Models), time.Since(cached.FetchedAt) >= ttl, nil
+}
+
+// discoverProviderModels asks a provider for its models and caches them, returning
+// them merged with the configured ones. It blocks for up to modelDiscoveryTimeout,
+// so the UI calls it through refreshProviderModels.
+func discoverProviderModels(name string) ([]ModelConfig, error) {
+	configured, err := config.GetModelsForProvider(name)
+	if err != nil {
+		return nil, err
+	}
+	providerConfig, err := config.GetProviderConfig(name)
+	if err != nil {
+		return configured, err
+	}
+
+	discovered, err := discoverModels(name, *providerConfig)
+	if err != nil {
+		return configured, err
+	}
+	merged := mergeModels(configured, discovered)
+	if err := saveModelsCache(name, discovered); err != nil {
+		return merged, err
+	}
+	return merged, nil
+}
+
+// refreshProviderModels discovers a provider's models in the background. Once they
+// arrive the models list is updated if the provider is still active, and onDone,
+// if set, runs on the UI thread with the models and any discovery error.
+func refreshProviderModels(g *gocui.Gui, name string, onDone func(g *gocui.Gui, refreshed []ModelConfig, err error) error) {
+	go func() {
+		refreshed, err := discoverProviderModels(name)
+		g.Update(func(g *gocui.Gui) error {
+			if len(refreshed) > 0 && len(models) > 0 && providers[activeProvider] == name {
+				activeName := models[activeModel].Name
+				models = refreshed
+				activeModel = 0
+				for i, model := range models {
+					if model.Name == activeName {
+						activeModel = i
+					}
+				}
+				selectedModel = min(selectedModel, len(models)-1)
+				if err := updateModelsView(g); err != nil {
+					return err
+				}
+			}
+			if onDone != nil {
+				return onDone(g, refreshed, err)
+			}
+			if err != nil {
+				setStatus(g, "\033[33m"+err.Error()+"\033[0m")
+			}
+			return nil
+		})
+	}()
+}
+
+// discoverModels asks a provider which chat models it offers
+func discoverModels(name string, providerConfig ProviderConfig) ([]string, error) {
+	provider, err := newProvider(name, providerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), modelDiscoveryTimeout)
+	defer cancel()
+	discovered, err := provider.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models for %s: %w", name, err)
+	}
+
+	chatModels := discovered[:0]
+	for _, model := range discovered {
+		if isChatModel(model) {
+			chatModels = append(chatModels, model)
+		}
+	}
+	return chatModels, nil
+}
+
+// nonChatModels are name fragments of models that can't be chatted with, such as
+// OpenAI's embedding, speech and image models
+var nonChatModels = []string{"embed", "whisper", "tts", "dall-e", "gpt-image", "moderation", "davinci", "babbage", "transcribe", "realtime", "audio", "search"}
+
+// isChatModel reports whether a discovered model looks like a chat model
+func isChatModel(name string) bool {
+	lower := strings.ToLower(name)
+	for _, fragment := range nonChatModels {
+		if strings.Contains(lower, fragment) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeModels appends discovered model names that are not already configured,
+// so configured entries keep their temperature and system prompt
+func mergeModels(configured []ModelConfig, discovered []string) []ModelConfig {
+	merged := append([]ModelConfig{}, configured...)
+	known := make(map[string]bool, len(configured))
+	for _, model := range configured {
+		known[model.Name] = true
+	}
+
+	for _, name := range discovered {
+		if known[name] {
+			continue
+		}
+		known[name] = true
+		merged = append(merged, ModelConfig{Name: name})
+	}
+	return merged
+}
+
+// GetModelsCachePath returns the file path of a provider's discovered models cache
+func GetModelsCachePath(provider string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	return filepath.Join(homeDir, ".config", "atlas", "model-cache", provider+".json"), nil
+}
+
+// loadModelsCache reads a provider's discovered models cache
+func loadModelsCache(provider string) (*modelsCache, error) {
+	cachePath, err := GetModelsCachePath(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read models cache: %w", err)
+	}
+
+	var cache modelsCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal models cache: %w", err)
+	}
+	return &cache, nil
+}
+
+// saveModelsCache writes a provider's discovered models cache
+func saveModelsCache(provider string, discovered []string) error {
+	cachePath, err := GetModelsCachePath(provider)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(modelsCache{FetchedAt: time.Now(), Models: discovered}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal models cache: %w", err)
+	}
+
+	if err := os.WriteFile(cachePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write models cache: %w", err)
+	}
+	return nil
+}
+
+// Refresh the active provider's discovered models in the background, keeping the
+// active model selected
+func refreshModels(g *gocui.Gui, v *gocui.View) error {
+	providerConfig, err := config.GetProviderConfig(providers[activeProvider])
+	if err != nil {
+		setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+		return nil
+	}
+	if !providerConfig.DiscoverModels {
+		setStatus(g, "Model discovery is off for "+providers[activeProvider]+" (set discover_models: true)")
+		return nil
+	}
+
+	name := providers[activeProvider]
+	setStatus(g, "Refreshing models for "+name+"...")
+	refreshProviderModels(g, name, func(g *gocui.Gui, refreshed []ModelConfig, err error) error {
+		if err != nil {
+			setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+			return nil
+		}
+		setStatus(g, fmt.Sprintf("Found %d models for %s", len(refreshed), name))
+		return nil
+	})
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/jroimartin/gocui"
@@ -86,8 +87,21 @@ func moveConvoDown(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+// waitingForReply reports whether a reply is still streaming into the current
+// conversation, which must not be switched away from until it finishes
+func waitingForReply(g *gocui.Gui) bool {
+	if !streaming {
+		return false
+	}
+	setStatus(g, "Wait for the reply to finish (Esc to cancel)")
+	return true
+}
+
 // Select the currently highlighted model as the active model
 func selectModel(g *gocui.Gui, v *gocui.View) error {
+	if waitingForReply(g) {
+		return nil
+	}
 	activeModel = selectedModel
 	updateModelsView(g)
 	updateProvidersView(g)
@@ -101,6 +115,7 @@ func selectModel(g *gocui.Gui, v *gocui.View) error {
 	}
 
 	// Create a new conversation with the selected model
+	resetChatState()
 	currentConvo = NewConvos("New Chat", providers[activeProvider], models[activeModel].Name)
 	currentConvo.AddMessage(openai.ChatMessageRoleSystem, models[activeModel].SystemPrompt)
 
@@ -127,13 +142,40 @@ func selectModel(g *gocui.Gui, v *gocui.View) error {
 
 // Select the currently highlighted provider as the active provider
 func selectProvider(g *gocui.Gui, v *gocui.View) error {
-	activeProvider = selectedProvider
-	// Get models for the selected provider from config
-	var err error
-	models, err = config.GetModelsForProvider(providers[activeProvider])
-	if err != nil {
-		log.Fatalf("Failed to get models: %v", err)
+	if waitingForReply(g) {
+		return nil
+	}
+	// Get models for the selected provider from config and its discovered models cache
+	name := providers[selectedProvider]
+	providerModels, stale, modelsErr := loadProviderModels(name)
+	if len(providerModels) == 0 && stale && modelsErr == nil {
+		// Nothing to show until discovery finishes; select the provider once it has
+		setStatus(g, "Discovering models for "+name+"...")
+		refreshProviderModels(g, name, func(g *gocui.Gui, refreshed []ModelConfig, err error) error {
+			if err != nil || len(refreshed) == 0 {
+				if err == nil {
+					err = fmt.Errorf("no models found for provider %s", name)
+				}
+				setStatus(g, "\033[31mFailed to get models: "+err.Error()+"\033[0m")
+				return nil
+			}
+			if providers[selectedProvider] != name {
+				return nil
+			}
+			return selectProvider(g, v)
+		})
+		return nil
 	}
+	if len(providerModels) == 0 {
+		if modelsErr == nil {
+			modelsErr = fmt.Errorf("no models configured for provider %s", name)
+		}
+		setStatus(g, "\033[31mFailed to get models: "+modelsErr.Error()+"\033[0m")
+		return nil
+	}
+
+	activeProvider = selectedProvider
+	models = providerModels
 	selectedModel = 0
 	activeModel = 0
 
@@ -149,6 +191,7 @@ func selectProvider(g *gocui.Gui, v *gocui.View) error {
 	}
 
 	// Create a new conversation with the selected provider and model
+	resetChatState()
 	currentConvo = NewConvos("New Chat", providers[activeProvider], models[activeModel].Name)
 	currentConvo.AddMessage(openai.ChatMessageRoleSystem, models[activeModel].SystemPrompt)
 
@@ -170,11 +213,23 @@ func selectProvider(g *gocui.Gui, v *gocui.View) error {
 	inputView.Clear()
 	inputView.SetCursor(0, 0)
 
+	if modelsErr != nil {
+		setStatus(g, "\033[33m"+modelsErr.Error()+"\033[0m")
+	} else {
+		setStatus(g, "")
+	}
+	if stale {
+		refreshProviderModels(g, name, nil)
+	}
+
 	return nil
 }
 
 // Select the currently highlighted conversation as the active conversation
 func selectConvo(g *gocui.Gui, v *gocui.View) error {
+	if waitingForReply(g) {
+		return nil
+	}
 	if selectedConvo < 0 || selectedConvo >= len(conversations) {
 		return nil
 	}
@@ -187,6 +242,7 @@ func selectConvo(g *gocui.Gui, v *gocui.View) error {
 	}
 
 	// Load the selected conversation
+	resetChatState()
 	loadConversation(selectedConvo)
 	updateConvosView(g)
 
@@ -195,22 +251,7 @@ func selectConvo(g *gocui.Gui, v *gocui.View) error {
 	if err != nil {
 		return err
 	}
-	chatLogView.Clear()
-
-	// Display all messages except the system prompt
-	for i, msg := range currentConvo.ChatHistory {
-		if i == 0 && msg.Role == openai.ChatMessageRoleSystem {
-			// Skip system prompt
-			continue
-		}
-
-		switch msg.Role {
-		case openai.ChatMessageRoleUser:
-			addUserMessage(chatLogView, msg.Content)
-		case openai.ChatMessageRoleAssistant:
-			addAIResponse(chatLogView, msg.Content)
-		}
-	}
+	renderChatLog(chatLogView)
 
 	return nil
 }
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+var (
+	showParams    = false // whether the parameters popup is open
+	selectedParam = 0
+)
+
+// paramRows are the parameters the popup adjusts, in display order
+var paramRows = []string{"temp", "max_tokens", "top_p", "seed", "presence_penalty", "frequency_penalty", "reasoning_effort", "stop"}
+
+// openParams opens the sampling parameters of the active model for this conversation
+func openParams(g *gocui.Gui, v *gocui.View) error {
+	showParams = true
+	if err := layoutParams(g); err != nil {
+		return err
+	}
+	g.Cursor = false
+	_, err := setCurrentViewOnTop(g, "params")
+	return err
+}
+
+// Close the parameters popup
+func closeParams(g *gocui.Gui, v *gocui.View) error {
+	showParams = false
+	if err := layoutParams(g); err != nil {
+		return err
+	}
+	if _, err := setCurrentViewOnTop(g, viewArr[active]); err != nil {
+		return err
+	}
+	g.Cursor = active == 4
+	return nil
+}
+
+// Move the selection up in the parameters popup
+func moveParamUp(g *gocui.Gui, v *gocui.View) error {
+	if selectedParam > 0 {
+		selectedParam--
+	}
+	return nil
+}
+
+// Move the selection down in the parameters popup
+func moveParamDown(g *gocui.Gui, v *gocui.View) error {
+	if selectedParam < len(paramRows)-1 {
+		selectedParam++
+	}
+	return nil
+}
+
+// Raise the selected parameter by one step
+func increaseParam(g *gocui.Gui, v *gocui.View) error {
+	return stepParam(g, 1)
+}
+
+// Lower the selected parameter by one step
+func decreaseParam(g *gocui.Gui, v *gocui.View) error {
+	return stepParam(g, -1)
+}
+
+// stepParam moves the selected parameter one step up or down, within its valid
+// range, and sets it for the current conversation
+func stepParam(g *gocui.Gui, direction int) error {
+	model := currentConvo.Params.apply(models[activeModel])
+	key := paramRows[selectedParam]
+
+	var value string
+	switch key {
+	case "temp":
+		// Without a temperature, step from 1, the usual provider default
+		temperature := float32(1)
+		if model.Temperature != nil {
+			temperature = *model.Temperature
+		}
+		value = formatParamFloat(stepFloat(temperature, 0.1, direction, 0, config.Providers[providers[activeProvider]].maxTemperature()))
+	case "top_p":
+		value = formatParamFloat(stepFloat(model.TopP, 0.05, direction, 0, 1))
+	case "presence_penalty":
+		value = formatParamFloat(stepFloat(model.PresencePenalty, 0.1, direction, -2, 2))
+	case "frequency_penalty":
+		value = formatParamFloat(stepFloat(model.FrequencyPenalty, 0.1, direction, -2, 2))
+	case "max_tokens":
+		value = strconv.Itoa(max(0, model.MaxTokens+256*direction))
+	case "seed":
+		// Stepping below 0 sends no seed, even if the model has one
+		seed := -1
+		if model.Seed != nil {
+			seed = *model.Seed
+		}
+		seed = max(-1, seed+direction)
+		value = strconv.Itoa(seed)
+		if seed < 0 {
+			value = "none"
+		}
+	case "reasoning_effort":
+		efforts := append([]string{"none"}, reasoningEfforts...)
+		index := 0
+		for i, effort := range efforts {
+			if effort == model.ReasoningEffort {
+				index = i
+			}
+		}
+		value = efforts[(index+direction+len(efforts))%len(efforts)]
+	case "stop":
+		setStatus(g, "Set stop sequences with /params stop=<seq>,<seq>")
+		return nil
+	}
+
+	override := SamplingOverride{}
+	if currentConvo.Params != nil {
+		override = *currentConvo.Params
+	}
+	if err := override.set(key, value); err != nil {
+		setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+		return nil
+	}
+	currentConvo.Params = &override
+	if override.isEmpty() {
+		currentConvo.Params = nil
+	}
+	return saveCurrentConversation()
+}
+
+// stepFloat adds one step to a float parameter, rounded to the step and kept in range
+func stepFloat(value, step float32, direction int, low, high float32) float32 {
+	steps := math.Round(float64(value/step)) + float64(direction)
+	return min(high, max(low, float32(steps)*step))
+}
+
+// formatParamFloat formats a float parameter without float32 rounding noise
+func formatParamFloat(value float32) string {
+	return strconv.FormatFloat(math.Round(float64(value)*100)/100, 'g', -1, 32)
+}
+
+// Reset the selected parameter to the model's value for this conversation
+func resetParam(g *gocui.Gui, v *gocui.View) error {
+	if currentConvo.Params == nil {
+		return nil
+	}
+	currentConvo.Params.unset(paramRows[selectedParam])
+	if currentConvo.Params.isEmpty() {
+		currentConvo.Params = nil
+	}
+	return saveCurrentConversation()
+}
+
+// Write the parameters in use to the active model's entry in config.yml, making
+// them the model's defaults
+func writeParams(g *gocui.Gui, v *gocui.View) error {
+	model := currentConvo.Params.apply(models[activeModel])
+	path, err := configPath()
+	if err == nil {
+		err = saveModelParams(path, providers[activeProvider], model)
+	}
+	if err != nil {
+		setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+		return nil
+	}
+
+	models[activeModel] = model
+	providerConfig := config.Providers[providers[activeProvider]]
+	found := false
+	for i, configured := range providerConfig.Models {
+		if configured.Name == model.Name {
+			providerConfig.Models[i] = model
+			found = true
+		}
+	}
+	if !found {
+		providerConfig.Models = append(providerConfig.Models, model)
+	}
+	config.Providers[providers[activeProvider]] = providerConfig
+
+	currentConvo.Params = nil
+	setStatus(g, "Saved the parameters of "+model.Name+" to "+path)
+	return saveCurrentConversation()
+}
+
+// layoutParams draws the parameters popup over the chat log while it is open
+func layoutParams(g *gocui.Gui) error {
+	if !showParams {
+		if err := g.DeleteView("params"); err != nil && err != gocui.ErrUnknownView {
+			return err
+		}
+		return nil
+	}
+
+	maxX, maxY := g.Size()
+	v, err := g.SetView("params", maxX/3, maxY/4, maxX-maxX/4, maxY/4+len(paramRows)+4)
+	if err != nil && err != gocui.ErrUnknownView {
+		return err
+	}
+	v.Title = "Parameters: " + models[activeModel].Name
+	v.Clear()
+
+	model := currentConvo.Params.apply(models[activeModel])
+	for i, key := range paramRows {
+		marker := " "
+		if currentConvo.Params.overrides(key) {
+			marker = "*"
+		}
+
+		value := paramValue(model, key)
+		if currentConvo.Params.sendsNone(key) {
+			value = "none"
+		}
+		line := fmt.Sprintf("%s %-18s %s", marker, key, value)
+		if i == selectedParam {
+			line = "\033[1m> " + line + "\033[0m"
+		} else {
+			line = "  " + line
+		}
+		fmt.Fprintln(v, line)
+	}
+	fmt.Fprintln(v)
+	fmt.Fprint(v, "\033[2m←/→ adjust  d default  w save to config  Esc close  (* this chat only)\033[0m")
+	return nil
+}
+
+// paramValue formats one parameter of a model for the parameters popup
+func paramValue(model ModelConfig, key string) string {
+	unset := "\033[2mdefault\033[0m"
+	switch key {
+	case "temp":
+		if model.Temperature != nil {
+			return formatParamFloat(*model.Temperature)
+		}
+	case "max_tokens":
+		if model.MaxTokens > 0 {
+			return strconv.Itoa(model.MaxTokens)
+		}
+	case "top_p":
+		if model.TopP > 0 {
+			return formatParamFloat(model.TopP)
+		}
+	case "seed":
+		if model.Seed != nil {
+			return strconv.Itoa(*model.Seed)
+		}
+	case "presence_penalty":
+		return formatParamFloat(model.PresencePenalty)
+	case "frequency_penalty":
+		return formatParamFloat(model.FrequencyPenalty)
+	case "reasoning_effort":
+		if model.ReasoningEffort != "" {
+			return model.ReasoningEffort
+		}
+	case "stop":
+		if len(model.Stop) > 0 {
+			quoted := make([]string, len(model.Stop))
+			for i, stop := range model.Stop {
+				quoted[i] = strconv.Quote(stop)
+			}
+			return strings.Join(quoted, ", ")
+		}
+	}
+	return unset
+}
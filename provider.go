@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// defaultProviderType is used when a provider's config has no type field
+const defaultProviderType = "openai"
+
+// ChatRequest is a provider-neutral chat completion request
+type ChatRequest struct {
+	Model       string
+	Messages    []openai.ChatCompletionMessage
+	Temperature *float32 // nil leaves it to the provider's default
+	Sampling    SamplingParams
+	Tools       []openai.Tool
+
+	// ResponseFormat asks for a JSON object, optionally matching a schema
+	ResponseFormat *openai.ChatCompletionResponseFormat
+}
+
+// TokenUsage is the number of tokens a provider reports for a request
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// ChatResponse is a complete reply from a provider. Reasoning is the thinking a
+// provider returns apart from the content, if any.
+type ChatResponse struct {
+	Content      string
+	Reasoning    string
+	ToolCalls    []openai.ToolCall
+	FinishReason string
+	Usage        *TokenUsage
+}
+
+// ChatDelta is one piece of a streamed reply. FinishReason and Usage are only set
+// on the pieces that carry them, usually the last ones. ToolCalls holds fragments
+// of tool calls that are merged by their Index.
+type ChatDelta struct {
+	Content      string
+	Reasoning    string
+	ToolCalls    []openai.ToolCall
+	FinishReason string
+	Usage        *TokenUsage
+}
+
+// ChatStream yields the pieces of a streamed reply until Recv returns io.EOF
+type ChatStream interface {
+	Recv() (ChatDelta, error)
+	Close() error
+}
+
+// Provider is a chat backend that atlas can send conversations to
+type Provider interface {
+	// Chat sends the request and waits for the whole reply
+	Chat(ctx context.Context, request ChatRequest) (ChatResponse, error)
+	// Stream sends the request and returns the reply as it is generated
+	Stream(ctx context.Context, request ChatRequest) (ChatStream, error)
+	// ListModels returns the names of the models the provider offers
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// ProviderFactory creates a provider from its name and configuration
+type ProviderFactory func(name string, config ProviderConfig) (Provider, error)
+
+// providerTypes maps a provider config's type field to its factory
+var providerTypes = map[string]ProviderFactory{}
+
+// registerProviderType makes a provider implementation available under the given type name
+func registerProviderType(providerType string, factory ProviderFactory) {
+	providerTypes[providerType] = factory
+}
+
+// newProvider creates the provider for a configured provider entry
+func newProvider(name string, config ProviderConfig) (Provider, error) {
+	providerType := config.Type
+	if providerType == "" {
+		providerType = defaultProviderType
+	}
+
+	factory, exists := providerTypes[providerType]
+	if !exists {
+		return nil, fmt.Errorf("unknown provider type %q for provider %s (known types: %v)", providerType, name, providerTypeNames())
+	}
+	return factory(name, config)
+}
+
+// providerTypeNames returns the registered provider types in sorted order
+func providerTypeNames() []string {
+	names := make([]string, 0, len(providerTypes))
+	for name := range providerTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
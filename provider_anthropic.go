@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	anthropicDefaultEndpoint  = "https://api.anthropic.com"
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+)
+
+func init() {
+	registerProviderType("anthropic", newAnthropicProvider)
+}
+
+// anthropicProvider talks to the Anthropic Messages API
+type anthropicProvider struct {
+	endpoint string
+	apiKey   string
+	client   openai.HTTPDoer
+}
+
+func newAnthropicProvider(name string, config ProviderConfig) (Provider, error) {
+	endpoint := strings.TrimSuffix(config.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = anthropicDefaultEndpoint
+	}
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("provider %s: api_key is required for anthropic", name)
+	}
+
+	return &anthropicProvider{
+		endpoint: endpoint,
+		apiKey:   config.APIKey,
+		client:   retryAfterRecorder{client: &http.Client{}},
+	}, nil
+}
+
+// anthropicMessage is a single turn in a Messages API request
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+// anthropicTool describes a tool the model may call
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+// anthropicRequest is the body of a Messages API request
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float32           `json:"temperature,omitempty"` // nil is the API default of 1, not 0
+	TopP        float32            `json:"top_p,omitempty"`
+	Stop        []string           `json:"stop_sequences,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+}
+
+// anthropicContentBlock is a block of a Messages API message: text, a tool call
+// (tool_use) or the result of one (tool_result)
+type anthropicContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	Source    *anthropicImage `json:"source,omitempty"`
+}
+
+// anthropicImage is the base64 source of an image block
+type anthropicImage struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// anthropicUsage is the token usage reported by the Messages API
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicResponse is the body of a non-streaming Messages API response
+type anthropicResponse struct {
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+// anthropicErrorResponse is the body the API sends with a failed request
+type anthropicErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicMessages converts the chat history into the Messages API format.
+// System messages move to the top-level system field, tool results are sent
+// as user turns and consecutive turns from the same role are merged, since
+// the API expects them to alternate.
+func anthropicMessages(history []openai.ChatCompletionMessage) (string, []anthropicMessage) {
+	var system []string
+	var messages []anthropicMessage
+
+	for _, msg := range history {
+		role := msg.Role
+		var blocks []anthropicContentBlock
+
+		switch msg.Role {
+		case openai.ChatMessageRoleSystem:
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+			continue
+		case openai.ChatMessageRoleUser, openai.ChatMessageRoleAssistant:
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicContentBlock{Type: "text", Text: msg.Content})
+			}
+			for _, part := range msg.MultiContent {
+				switch {
+				case part.Type == openai.ChatMessagePartTypeText && part.Text != "":
+					blocks = append(blocks, anthropicContentBlock{Type: "text", Text: part.Text})
+				case part.Type == openai.ChatMessagePartTypeImageURL && part.ImageURL != nil:
+					if mediaType, data, ok := parseDataURL(part.ImageURL.URL); ok {
+						blocks = append(blocks, anthropicContentBlock{
+							Type:   "image",
+							Source: &anthropicImage{Type: "base64", MediaType: mediaType, Data: data},
+						})
+					}
+				}
+			}
+			for _, call := range msg.ToolCalls {
+				input := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicContentBlock{Type: "tool_use", ID: call.ID, Name: call.Function.Name, Input: input})
+			}
+		case openai.ChatMessageRoleTool:
+			role = openai.ChatMessageRoleUser
+			blocks = append(blocks, anthropicContentBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		if last := len(messages) - 1; last >= 0 && messages[last].Role == role {
+			messages[last].Content = append(messages[last].Content, blocks...)
+			continue
+		}
+		messages = append(messages, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	return strings.Join(system, "\n\n"), messages
+}
+
+// anthropicTools converts OpenAI-style function tools into the Messages API format
+func anthropicTools(tools []openai.Tool) []anthropicTool {
+	var converted []anthropicTool
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		converted = append(converted, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: tool.Function.Parameters,
+		})
+	}
+	return converted
+}
+
+// newRequest builds an authenticated request against the Messages API
+func (p *anthropicProvider) newRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.endpoint+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	req.Header.Set("content-type", "application/json")
+	return req, nil
+}
+
+// do sends a request and turns non-2xx responses into a ProviderError
+func (p *anthropicProvider) do(req *http.Request) (*http.Response, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	providerErr := &ProviderError{StatusCode: resp.StatusCode, Message: resp.Status}
+	var errResp anthropicErrorResponse
+	if data, err := io.ReadAll(resp.Body); err == nil && json.Unmarshal(data, &errResp) == nil && errResp.Error.Message != "" {
+		providerErr.Type = errResp.Error.Type
+		providerErr.Message = errResp.Error.Message
+	}
+	return nil, providerErr
+}
+
+// messagesRequest converts a chat request into the Messages API format
+func (p *anthropicProvider) messagesRequest(request ChatRequest, stream bool) anthropicRequest {
+	system, messages := anthropicMessages(request.Messages)
+
+	// The Messages API has no JSON mode, so the format is requested in the system prompt
+	if request.ResponseFormat != nil {
+		instruction := "Reply with a single JSON object and nothing else."
+		if schema := formatSchema(request.ResponseFormat); schema != nil {
+			data, _ := json.Marshal(schema)
+			instruction += " The object must match this JSON schema: " + string(data)
+		}
+		system = strings.TrimSpace(system + "\n\n" + instruction)
+	}
+
+	// max_tokens is required by the Messages API; seed, penalties and
+	// reasoning_effort have no equivalent there
+	maxTokens := anthropicDefaultMaxTokens
+	if request.Sampling.MaxTokens > 0 {
+		maxTokens = request.Sampling.MaxTokens
+	}
+
+	return anthropicRequest{
+		Model:       request.Model,
+		System:      system,
+		Messages:    messages,
+		MaxTokens:   maxTokens,
+		Temperature: request.Temperature,
+		TopP:        request.Sampling.TopP,
+		Stop:        request.Sampling.Stop,
+		Tools:       anthropicTools(request.Tools),
+		Stream:      stream,
+	}
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	req, err := p.newRequest(ctx, http.MethodPost, "/v1/messages", p.messagesRequest(request, false))
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var body anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return ChatResponse{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	var content strings.Builder
+	var toolCalls []openai.ToolCall
+	for _, block := range body.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "tool_use":
+			toolCalls = append(toolCalls, openai.ToolCall{
+				ID:       block.ID,
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+	return ChatResponse{
+		Content:      content.String(),
+		ToolCalls:    toolCalls,
+		FinishReason: body.StopReason,
+		Usage:        &TokenUsage{PromptTokens: body.Usage.InputTokens, CompletionTokens: body.Usage.OutputTokens},
+	}, nil
+}
+
+func (p *anthropicProvider) Stream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	req, err := p.newRequest(ctx, http.MethodPost, "/v1/messages", p.messagesRequest(request, true))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "text/event-stream")
+
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return &anthropicStream{body: resp.Body, reader: bufio.NewReader(resp.Body)}, nil
+}
+
+func (p *anthropicProvider) ListModels(ctx context.Context) ([]string, error) {
+	req, err := p.newRequest(ctx, http.MethodGet, "/v1/models?limit=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode models: %w", err)
+	}
+
+	names := make([]string, 0, len(body.Data))
+	for _, model := range body.Data {
+		names = append(names, model.ID)
+	}
+	return names, nil
+}
+
+// anthropicStreamEvent is the data payload of a Messages API server-sent event
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Usage        anthropicUsage        `json:"usage"`
+	ContentBlock anthropicContentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicStream reads server-sent events from a streaming Messages API response
+type anthropicStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	usage  TokenUsage
+}
+
+// nextEvent reads the data lines of the next server-sent event
+func (s *anthropicStream) nextEvent() ([]byte, error) {
+	var data []byte
+	for {
+		line, err := s.reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data:"))...)
+		}
+
+		// A blank line ends the event
+		if (line == "" || err != nil) && len(data) > 0 {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *anthropicStream) Recv() (ChatDelta, error) {
+	for {
+		data, err := s.nextEvent()
+		if err != nil {
+			return ChatDelta{}, err
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return ChatDelta{}, fmt.Errorf("failed to decode stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			s.usage.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				return ChatDelta{ToolCalls: []openai.ToolCall{{
+					Index:    &event.Index,
+					ID:       event.ContentBlock.ID,
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: event.ContentBlock.Name},
+				}}}, nil
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				return ChatDelta{Content: event.Delta.Text}, nil
+			case "input_json_delta":
+				return ChatDelta{ToolCalls: []openai.ToolCall{{
+					Index:    &event.Index,
+					Function: openai.FunctionCall{Arguments: event.Delta.PartialJSON},
+				}}}, nil
+			}
+		case "message_delta":
+			s.usage.CompletionTokens = event.Usage.OutputTokens
+			usage := s.usage
+			return ChatDelta{FinishReason: event.Delta.StopReason, Usage: &usage}, nil
+		case "message_stop":
+			return ChatDelta{}, io.EOF
+		case "error":
+			return ChatDelta{}, &ProviderError{
+				StatusCode: anthropicStreamErrorStatus(event.Error.Type),
+				Type:       event.Error.Type,
+				Message:    event.Error.Message,
+			}
+		}
+	}
+}
+
+func (s *anthropicStream) Close() error {
+	return s.body.Close()
+}
+
+// anthropicStreamErrorStatus maps an error event type to the HTTP status it stands for,
+// so errors sent mid-stream are retried and described like regular ones
+func anthropicStreamErrorStatus(errorType string) int {
+	switch errorType {
+	case "overloaded_error":
+		return 529
+	case "rate_limit_error":
+		return http.StatusTooManyRequests
+	case "api_error":
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// anthropicStandIn serves canned Messages API responses and records the last request
+type anthropicStandIn struct {
+	header http.Header
+	body   map[string]any
+	events []string
+}
+
+func (s *anthropicStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.header = r.Header.Clone()
+	if err := json.NewDecoder(r.Body).Decode(&s.body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if stream, _ := s.body["stream"].(bool); !stream {
+		w.Header().Set("content-type", "application/json")
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"Hello there"}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":3}}`)
+		return
+	}
+
+	w.Header().Set("content-type", "text/event-stream")
+	for _, event := range s.events {
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", event)
+	}
+}
+
+func newAnthropicStandIn(t *testing.T, events ...string) (*anthropicStandIn, Provider) {
+	t.Helper()
+	standIn := &anthropicStandIn{events: events}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	provider, err := newAnthropicProvider("anthropic", ProviderConfig{Endpoint: server.URL, APIKey: "test-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return standIn, provider
+}
+
+var anthropicTestRequest = ChatRequest{
+	Model:       "claude-test",
+	Temperature: new(float32),
+	Messages: []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: openai.ChatMessageRoleUser, Content: "Hi"},
+	},
+}
+
+func TestAnthropicChatRequest(t *testing.T) {
+	standIn, provider := newAnthropicStandIn(t)
+
+	response, err := provider.Chat(context.Background(), anthropicTestRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := standIn.header.Get("x-api-key"); got != "test-key" {
+		t.Errorf("x-api-key = %q, want test-key", got)
+	}
+	if got := standIn.header.Get("anthropic-version"); got != anthropicVersion {
+		t.Errorf("anthropic-version = %q, want %s", got, anthropicVersion)
+	}
+	if got := standIn.body["system"]; got != "Be brief." {
+		t.Errorf("system = %v, want the system prompt", got)
+	}
+	if messages, _ := standIn.body["messages"].([]any); len(messages) != 1 {
+		t.Errorf("messages = %v, want only the user message", standIn.body["messages"])
+	}
+	if got, ok := standIn.body["temperature"]; !ok || got != 0.0 {
+		t.Errorf("temperature = %v (sent: %t), want an explicit 0", got, ok)
+	}
+
+	if response.Content != "Hello there" || response.FinishReason != "end_turn" {
+		t.Errorf("response = %q (%s), want Hello there (end_turn)", response.Content, response.FinishReason)
+	}
+	if response.Usage == nil || response.Usage.PromptTokens != 12 || response.Usage.CompletionTokens != 3 {
+		t.Errorf("usage = %+v, want 12 prompt and 3 completion tokens", response.Usage)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	standIn, provider := newAnthropicStandIn(t,
+		`{"type":"message_start","message":{"usage":{"input_tokens":20}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"ping"}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":2}}`,
+		`{"type":"message_stop"}`,
+	)
+
+	stream, err := provider.Stream(context.Background(), anthropicTestRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	if got := standIn.header.Get("accept"); got != "text/event-stream" {
+		t.Errorf("accept = %q, want text/event-stream", got)
+	}
+
+	var content strings.Builder
+	var finishReason string
+	var usage *TokenUsage
+	for {
+		delta, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content.WriteString(delta.Content)
+		if delta.FinishReason != "" {
+			finishReason = delta.FinishReason
+		}
+		if delta.Usage != nil {
+			usage = delta.Usage
+		}
+	}
+
+	if content.String() != "Hello" {
+		t.Errorf("content = %q, want Hello", content.String())
+	}
+	if finishReason != "max_tokens" {
+		t.Errorf("stop reason = %q, want max_tokens", finishReason)
+	}
+	if usage == nil || usage.PromptTokens != 20 || usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v, want 20 prompt and 2 completion tokens", usage)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	_, provider := newAnthropicStandIn(t,
+		`{"type":"message_start","message":{"usage":{"input_tokens":20}}}`,
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	)
+
+	stream, err := provider.Stream(context.Background(), anthropicTestRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	_, err = stream.Recv()
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || providerErr.StatusCode != 529 {
+		t.Fatalf("err = %v, want an overloaded ProviderError", err)
+	}
+	if !shouldRetry(err) {
+		t.Error("an overloaded error mid-stream should be retried")
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	mockDefaultDelay       = 20 * time.Millisecond
+	mockDefaultErrorStatus = http.StatusServiceUnavailable
+)
+
+func init() {
+	registerProviderType("mock", newMockProvider)
+}
+
+// MockConfig configures the offline mock provider. Without a script every reply
+// echoes the last user message.
+type MockConfig struct {
+	Script      string        `yaml:"script,omitempty"`       // YAML file of canned replies, relative to the config file
+	Delay       time.Duration `yaml:"delay,omitempty"`        // pause between streamed words, default 20ms
+	FailEvery   int           `yaml:"fail_every,omitempty"`   // every nth request fails
+	ErrorStatus int           `yaml:"error_status,omitempty"` // status of injected failures, default 503
+}
+
+// mockScriptEntry is one canned reply of a mock script. The first entry whose
+// match pattern matches the last user message answers it; an entry without a
+// pattern matches anything. An entry with an error status fails the request.
+// An entry with tool calls makes them first and gives its reply once the tool
+// results are sent back.
+type mockScriptEntry struct {
+	Match     string         `yaml:"match,omitempty"`
+	Reply     string         `yaml:"reply,omitempty"`
+	Error     int            `yaml:"error,omitempty"`
+	ToolCalls []mockToolCall `yaml:"tool_calls,omitempty"`
+}
+
+// mockToolCall is a tool call made by a mock script entry
+type mockToolCall struct {
+	Name      string `yaml:"name"`
+	Arguments string `yaml:"arguments,omitempty"` // JSON object, default {}
+}
+
+// mockWord splits a reply into the words it is streamed in, keeping the
+// whitespace after each word
+var mockWord = regexp.MustCompile(`\S+\s*|\s+`)
+
+// mockRequests counts the requests each mock provider has received. Providers
+// are created per request, so the count is kept by provider name.
+var (
+	mockMu       sync.Mutex
+	mockRequests = map[string]int{}
+)
+
+// mockProvider answers from an echo or a script without any network access, so
+// the whole request path can be exercised offline
+type mockProvider struct {
+	name   string
+	models []ModelConfig
+	config MockConfig
+}
+
+func newMockProvider(name string, config ProviderConfig) (Provider, error) {
+	mock := MockConfig{}
+	if config.Mock != nil {
+		mock = *config.Mock
+	}
+	if mock.Delay == 0 {
+		mock.Delay = mockDefaultDelay
+	}
+	if mock.ErrorStatus == 0 {
+		mock.ErrorStatus = mockDefaultErrorStatus
+	}
+	return &mockProvider{name: name, models: config.Models, config: mock}, nil
+}
+
+// reply works out the response to a request, counting it towards fail_every
+func (p *mockProvider) reply(request ChatRequest) (ChatResponse, error) {
+	mockMu.Lock()
+	mockRequests[p.name]++
+	count := mockRequests[p.name]
+	mockMu.Unlock()
+
+	if p.config.FailEvery > 0 && count%p.config.FailEvery == 0 {
+		return ChatResponse{}, &ProviderError{
+			StatusCode: p.config.ErrorStatus,
+			Message:    fmt.Sprintf("injected failure on request %d", count),
+		}
+	}
+
+	input := ""
+	for i := len(request.Messages) - 1; i >= 0; i-- {
+		if request.Messages[i].Role == openai.ChatMessageRoleUser {
+			input = messageText(request.Messages[i])
+			break
+		}
+	}
+
+	if p.config.Script != "" {
+		entry, found, err := p.scriptEntry(input)
+		if err != nil {
+			return ChatResponse{}, err
+		}
+		if found && entry.Error != 0 {
+			return ChatResponse{}, &ProviderError{StatusCode: entry.Error, Message: "scripted failure"}
+		}
+		if found {
+			return entry.response(request), nil
+		}
+	}
+
+	// JSON mode gets the echo wrapped in an object so it still validates
+	if request.ResponseFormat != nil {
+		data, _ := json.Marshal(map[string]string{"echo": input})
+		return ChatResponse{Content: string(data), FinishReason: "stop"}, nil
+	}
+	return ChatResponse{Content: "You said: " + input, FinishReason: "stop"}, nil
+}
+
+// response makes the entry's tool calls, or gives its reply once the request
+// carries their results
+func (e mockScriptEntry) response(request ChatRequest) ChatResponse {
+	last := len(request.Messages) - 1
+	if len(e.ToolCalls) == 0 || last >= 0 && request.Messages[last].Role == openai.ChatMessageRoleTool {
+		return ChatResponse{Content: e.Reply, FinishReason: "stop"}
+	}
+
+	calls := make([]openai.ToolCall, len(e.ToolCalls))
+	for i, call := range e.ToolCalls {
+		arguments := call.Arguments
+		if arguments == "" {
+			arguments = "{}"
+		}
+		calls[i] = openai.ToolCall{
+			Index:    &i,
+			ID:       fmt.Sprintf("mock_call_%d", i+1),
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: call.Name, Arguments: arguments},
+		}
+	}
+	return ChatResponse{ToolCalls: calls, FinishReason: "tool_calls"}
+}
+
+// scriptEntry returns the first script entry that matches the input. The script
+// is read on every request so it can be edited while atlas runs.
+func (p *mockProvider) scriptEntry(input string) (mockScriptEntry, bool, error) {
+	data, err := os.ReadFile(p.config.Script)
+	if err != nil {
+		return mockScriptEntry{}, false, fmt.Errorf("failed to read mock script: %w", err)
+	}
+	var script []mockScriptEntry
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return mockScriptEntry{}, false, fmt.Errorf("failed to parse mock script: %w", err)
+	}
+
+	for _, entry := range script {
+		if entry.Match == "" {
+			return entry, true, nil
+		}
+		pattern, err := regexp.Compile(entry.Match)
+		if err != nil {
+			return mockScriptEntry{}, false, fmt.Errorf("invalid match %q in mock script: %w", entry.Match, err)
+		}
+		if pattern.MatchString(input) {
+			return entry, true, nil
+		}
+	}
+	return mockScriptEntry{}, false, nil
+}
+
+// usage estimates token counts the way replies without reported usage are counted
+func (p *mockProvider) usage(request ChatRequest, reply ChatResponse) *TokenUsage {
+	completion := estimateTokens(reply.Content)
+	for _, call := range reply.ToolCalls {
+		completion += estimateTokens(call.Function.Name + call.Function.Arguments)
+	}
+	return &TokenUsage{PromptTokens: estimateHistoryTokens(request.Messages), CompletionTokens: completion}
+}
+
+func (p *mockProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	reply, err := p.reply(request)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	reply.Usage = p.usage(request, reply)
+	return reply, nil
+}
+
+func (p *mockProvider) Stream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	reply, err := p.reply(request)
+	if err != nil {
+		return nil, err
+	}
+	reply.Usage = p.usage(request, reply)
+
+	chunks := mockWord.FindAllString(reply.Content, -1)
+	return &mockStream{ctx: ctx, chunks: chunks, delay: p.config.Delay, last: reply}, nil
+}
+
+func (p *mockProvider) ListModels(ctx context.Context) ([]string, error) {
+	names := make([]string, 0, len(p.models))
+	for _, model := range p.models {
+		names = append(names, model.Name)
+	}
+	return names, nil
+}
+
+// mockStream yields a canned reply a word at a time, then its tool calls, finish
+// reason and usage
+type mockStream struct {
+	ctx    context.Context
+	chunks []string
+	delay  time.Duration
+	last   ChatResponse
+	done   bool
+}
+
+func (s *mockStream) Recv() (ChatDelta, error) {
+	if s.done {
+		return ChatDelta{}, io.EOF
+	}
+	if len(s.chunks) == 0 {
+		s.done = true
+		return ChatDelta{ToolCalls: s.last.ToolCalls, FinishReason: s.last.FinishReason, Usage: s.last.Usage}, nil
+	}
+
+	select {
+	case <-s.ctx.Done():
+		return ChatDelta{}, s.ctx.Err()
+	case <-time.After(s.delay):
+	}
+
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return ChatDelta{Content: chunk}, nil
+}
+
+func (s *mockStream) Close() error {
+	return nil
+}
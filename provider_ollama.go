@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const ollamaDefaultEndpoint = "http://localhost:11434"
+
+func init() {
+	registerProviderType("ollama", newOllamaProvider)
+}
+
+// ollamaProvider talks to Ollama's native /api/chat and /api/tags endpoints
+type ollamaProvider struct {
+	endpoint string
+	models   []ModelConfig
+	client   openai.HTTPDoer
+}
+
+func newOllamaProvider(name string, config ProviderConfig) (Provider, error) {
+	endpoint := strings.TrimSuffix(config.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = ollamaDefaultEndpoint
+	}
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+
+	return &ollamaProvider{
+		endpoint: endpoint,
+		models:   config.Models,
+		client:   retryAfterRecorder{client: &http.Client{}},
+	}, nil
+}
+
+// ollamaMessage is a single turn in an /api/chat request or response
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"` // set when the model thinks separately
+	Images    []string         `json:"images,omitempty"`   // base64, without a data URL prefix
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+// ollamaToolCall is a tool call in an /api/chat message. Unlike OpenAI, the
+// arguments are a JSON object rather than a string and calls have no ID.
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaChatRequest is the body of an /api/chat request
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Tools     []openai.Tool   `json:"tools,omitempty"`
+	Format    json.RawMessage `json:"format,omitempty"` // "json" or a JSON schema
+	Stream    bool            `json:"stream"`
+	Options   map[string]any  `json:"options,omitempty"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+}
+
+// ollamaChatResponse is a non-streaming reply or one line of a streamed reply
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// usage returns the token counts Ollama reports with the final chunk
+func (r ollamaChatResponse) usage() *TokenUsage {
+	if !r.Done {
+		return nil
+	}
+	return &TokenUsage{PromptTokens: r.PromptEvalCount, CompletionTokens: r.EvalCount}
+}
+
+// chatRequest converts a chat request into the /api/chat format, applying the
+// Ollama options and keep_alive configured for the model
+func (p *ollamaProvider) chatRequest(request ChatRequest, stream bool) ollamaChatRequest {
+	messages := make([]ollamaMessage, 0, len(request.Messages))
+	for _, msg := range request.Messages {
+		if msg.Role == openai.ChatMessageRoleSystem && msg.Content == "" {
+			continue
+		}
+		converted := ollamaMessage{Role: msg.Role, Content: messageText(msg)}
+		for _, part := range msg.MultiContent {
+			if part.Type != openai.ChatMessagePartTypeImageURL || part.ImageURL == nil {
+				continue
+			}
+			if _, data, ok := parseDataURL(part.ImageURL.URL); ok {
+				converted.Images = append(converted.Images, data)
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			var toolCall ollamaToolCall
+			toolCall.Function.Name = call.Function.Name
+			toolCall.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if !json.Valid(toolCall.Function.Arguments) {
+				toolCall.Function.Arguments = json.RawMessage("{}")
+			}
+			converted.ToolCalls = append(converted.ToolCalls, toolCall)
+		}
+		messages = append(messages, converted)
+	}
+
+	// Sampling parameters map to Ollama options; options set in the model's
+	// config take precedence
+	options := ollamaSamplingOptions(request.Sampling)
+	keepAlive := ""
+	for _, model := range p.models {
+		if model.Name == request.Model {
+			for key, value := range model.Options {
+				options[key] = value
+			}
+			keepAlive = model.KeepAlive
+			break
+		}
+	}
+	// A requested temperature is sent even when it is 0, unless the options set one
+	if _, set := options["temperature"]; !set && request.Temperature != nil {
+		options["temperature"] = *request.Temperature
+	}
+
+	var format json.RawMessage
+	if request.ResponseFormat != nil {
+		format = json.RawMessage(`"json"`)
+		if schema := formatSchema(request.ResponseFormat); schema != nil {
+			format, _ = json.Marshal(schema)
+		}
+	}
+
+	return ollamaChatRequest{
+		Format:    format,
+		Model:     request.Model,
+		Messages:  messages,
+		Tools:     request.Tools,
+		Stream:    stream,
+		Options:   options,
+		KeepAlive: keepAlive,
+	}
+}
+
+// ollamaSamplingOptions converts sampling parameters into /api/chat options.
+// reasoning_effort has no Ollama equivalent.
+func ollamaSamplingOptions(params SamplingParams) map[string]any {
+	options := map[string]any{}
+	if params.MaxTokens > 0 {
+		options["num_predict"] = params.MaxTokens
+	}
+	if params.TopP > 0 {
+		options["top_p"] = params.TopP
+	}
+	if len(params.Stop) > 0 {
+		options["stop"] = params.Stop
+	}
+	if params.Seed != nil {
+		options["seed"] = *params.Seed
+	}
+	if params.PresencePenalty != 0 {
+		options["presence_penalty"] = params.PresencePenalty
+	}
+	if params.FrequencyPenalty != 0 {
+		options["frequency_penalty"] = params.FrequencyPenalty
+	}
+	return options
+}
+
+// do sends a JSON request and turns non-2xx responses into a ProviderError
+func (p *ollamaProvider) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.endpoint+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	providerErr := &ProviderError{StatusCode: resp.StatusCode, Message: resp.Status}
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if data, err := io.ReadAll(resp.Body); err == nil && json.Unmarshal(data, &errResp) == nil && errResp.Error != "" {
+		providerErr.Message = errResp.Error
+	}
+	return nil, providerErr
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	resp, err := p.do(ctx, http.MethodPost, "/api/chat", p.chatRequest(request, false))
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var body ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return ChatResponse{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return ChatResponse{
+		Content:      body.Message.Content,
+		Reasoning:    body.Message.Thinking,
+		ToolCalls:    ollamaToolCalls(body.Message.ToolCalls, 0),
+		FinishReason: body.DoneReason,
+		Usage:        body.usage(),
+	}, nil
+}
+
+func (p *ollamaProvider) Stream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	resp, err := p.do(ctx, http.MethodPost, "/api/chat", p.chatRequest(request, true))
+	if err != nil {
+		return nil, err
+	}
+	// A single chunk can be larger than the scanner's default 64KB limit, e.g. a
+	// tool call with a whole file as its argument
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &ollamaStream{body: resp.Body, scanner: scanner}, nil
+}
+
+func (p *ollamaProvider) ListModels(ctx context.Context) ([]string, error) {
+	resp, err := p.do(ctx, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode models: %w", err)
+	}
+
+	names := make([]string, 0, len(body.Models))
+	for _, model := range body.Models {
+		names = append(names, model.Name)
+	}
+	return names, nil
+}
+
+// ollamaToolCalls converts tool calls from an /api/chat message, numbering them
+// from the given index. Ollama sends every call whole, so each gets its own index
+// and a generated ID.
+func ollamaToolCalls(calls []ollamaToolCall, first int) []openai.ToolCall {
+	var converted []openai.ToolCall
+	for i, call := range calls {
+		index := first + i
+		converted = append(converted, openai.ToolCall{
+			Index:    &index,
+			ID:       fmt.Sprintf("call_%d", index),
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: call.Function.Name, Arguments: string(call.Function.Arguments)},
+		})
+	}
+	return converted
+}
+
+// ollamaStream reads the newline-delimited JSON of a streamed /api/chat reply
+type ollamaStream struct {
+	body      io.ReadCloser
+	scanner   *bufio.Scanner
+	done      bool
+	toolCalls int
+}
+
+func (s *ollamaStream) Recv() (ChatDelta, error) {
+	for {
+		if s.done || !s.scanner.Scan() {
+			if err := s.scanner.Err(); err != nil {
+				return ChatDelta{}, err
+			}
+			return ChatDelta{}, io.EOF
+		}
+
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return ChatDelta{}, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return ChatDelta{}, &ProviderError{StatusCode: http.StatusInternalServerError, Message: chunk.Error}
+		}
+
+		s.done = chunk.Done
+		toolCalls := ollamaToolCalls(chunk.Message.ToolCalls, s.toolCalls)
+		s.toolCalls += len(toolCalls)
+		return ChatDelta{
+			Content:      chunk.Message.Content,
+			Reasoning:    chunk.Message.Thinking,
+			ToolCalls:    toolCalls,
+			FinishReason: chunk.DoneReason,
+			Usage:        chunk.usage(),
+		}, nil
+	}
+}
+
+func (s *ollamaStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ollamaStandIn serves canned /api/chat replies and records the last request
+type ollamaStandIn struct {
+	body  map[string]any
+	lines []string
+}
+
+func (s *ollamaStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.body = nil
+	if err := json.NewDecoder(r.Body).Decode(&s.body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("content-type", "application/x-ndjson")
+	if stream, _ := s.body["stream"].(bool); !stream {
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"Hello there","thinking":"Greet back."},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":3}`)
+		return
+	}
+	for _, line := range s.lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+func newOllamaStandIn(t *testing.T, models []ModelConfig, lines ...string) (*ollamaStandIn, Provider) {
+	t.Helper()
+	standIn := &ollamaStandIn{lines: lines}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	provider, err := newOllamaProvider("ollama", ProviderConfig{Endpoint: server.URL, Models: models})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return standIn, provider
+}
+
+func TestOllamaChatRequest(t *testing.T) {
+	seed := 7
+	models := []ModelConfig{{Name: "llama3", Options: map[string]any{"num_ctx": 8192, "top_p": 0.5}, KeepAlive: "10m"}}
+	standIn, provider := newOllamaStandIn(t, models)
+
+	request := ChatRequest{
+		Model:       "llama3",
+		Temperature: new(float32),
+		Sampling:    SamplingParams{MaxTokens: 256, TopP: 0.9, Seed: &seed},
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem},
+			{Role: openai.ChatMessageRoleUser, MultiContent: []openai.ChatMessagePart{
+				{Type: openai.ChatMessagePartTypeText, Text: "What is this?"},
+				{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "data:image/png;base64,iVBORw0K"}},
+			}},
+			{Role: openai.ChatMessageRoleAssistant, ToolCalls: []openai.ToolCall{{ID: "call_0", Function: openai.FunctionCall{Name: "list_dir", Arguments: `{"path": "."}`}}}},
+			{Role: openai.ChatMessageRoleTool, ToolCallID: "call_0", Content: "main.go"},
+		},
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type:       openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{Name: "answer", Schema: json.RawMessage(`{"type":"object"}`)},
+		},
+	}
+	response, err := provider.Chat(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages, _ := standIn.body["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("messages = %v, want the empty system prompt left out", standIn.body["messages"])
+	}
+	user, _ := messages[0].(map[string]any)
+	if images, _ := user["images"].([]any); user["content"] != "What is this?" || len(images) != 1 || images[0] != "iVBORw0K" {
+		t.Errorf("user message = %v, want the text and the image without its data URL prefix", user)
+	}
+	call, _ := messages[1].(map[string]any)["tool_calls"].([]any)
+	if len(call) != 1 || fmt.Sprint(call[0]) != "map[function:map[arguments:map[path:.] name:list_dir]]" {
+		t.Errorf("tool calls = %v, want the arguments as an object", call)
+	}
+
+	options, _ := standIn.body["options"].(map[string]any)
+	want := map[string]any{"num_predict": 256.0, "top_p": 0.5, "seed": 7.0, "num_ctx": 8192.0, "temperature": 0.0}
+	if fmt.Sprint(options) != fmt.Sprint(want) {
+		t.Errorf("options = %v, want %v with the model's options taking precedence", options, want)
+	}
+	if standIn.body["keep_alive"] != "10m" || standIn.body["stream"] != false {
+		t.Errorf("keep_alive, stream = %v, %v; want 10m, false", standIn.body["keep_alive"], standIn.body["stream"])
+	}
+	if format, _ := standIn.body["format"].(map[string]any); format["type"] != "object" {
+		t.Errorf("format = %v, want the schema", standIn.body["format"])
+	}
+
+	if response.Content != "Hello there" || response.Reasoning != "Greet back." || response.FinishReason != "stop" {
+		t.Errorf("response = %+v, want the reply, its thinking and the done reason", response)
+	}
+	if response.Usage == nil || response.Usage.PromptTokens != 12 || response.Usage.CompletionTokens != 3 {
+		t.Errorf("usage = %+v, want 12 prompt and 3 completion tokens", response.Usage)
+	}
+}
+
+func TestOllamaRequestDefaults(t *testing.T) {
+	standIn, provider := newOllamaStandIn(t, nil)
+	request := ChatRequest{Model: "llama3", Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Hi"}}}
+	if _, err := provider.Chat(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"options", "format", "keep_alive", "tools"} {
+		if value, sent := standIn.body[key]; sent {
+			t.Errorf("%s = %v, want it left to Ollama's default", key, value)
+		}
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	long := strings.Repeat("word ", 20000) // a single line over the scanner's default 64KB
+	_, provider := newOllamaStandIn(t, nil,
+		`{"message":{"role":"assistant","content":"","thinking":"Let me look."},"done":false}`,
+		``,
+		`{"message":{"role":"assistant","content":"Here: "},"done":false}`,
+		`{"message":{"role":"assistant","content":"`+long+`"},"done":false}`,
+		`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"list_dir","arguments":{"path":"."}}},{"function":{"name":"grep","arguments":{"pattern":"x"}}}]},"done":false}`,
+		`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"read_file","arguments":{"path":"go.mod"}}}]},"done":false}`,
+		`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":20,"eval_count":9}`,
+	)
+
+	stream, err := provider.Stream(context.Background(), ChatRequest{Model: "llama3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var content, reasoning strings.Builder
+	var calls []openai.ToolCall
+	var finishReason string
+	var usage *TokenUsage
+	for {
+		delta, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content.WriteString(delta.Content)
+		reasoning.WriteString(delta.Reasoning)
+		calls = append(calls, delta.ToolCalls...)
+		if delta.FinishReason != "" {
+			finishReason = delta.FinishReason
+		}
+		if delta.Usage != nil {
+			usage = delta.Usage
+		}
+	}
+
+	if content.String() != "Here: "+long || reasoning.String() != "Let me look." {
+		t.Errorf("content is %d bytes, reasoning = %q; want the whole reply and the thinking", content.Len(), reasoning.String())
+	}
+	if len(calls) != 3 || calls[2].ID != "call_2" || *calls[2].Index != 2 || calls[0].Function.Arguments != `{"path":"."}` {
+		t.Errorf("tool calls = %+v, want three numbered calls across chunks", calls)
+	}
+	if finishReason != "stop" || usage == nil || usage.PromptTokens != 20 || usage.CompletionTokens != 9 {
+		t.Errorf("finish reason, usage = %q, %+v; want stop with 20 prompt and 9 completion tokens", finishReason, usage)
+	}
+}
+
+func TestOllamaStreamError(t *testing.T) {
+	_, provider := newOllamaStandIn(t, nil,
+		`{"message":{"role":"assistant","content":"Hi"},"done":false}`,
+		`{"error":"model runner has unexpectedly stopped"}`,
+	)
+
+	stream, err := provider.Stream(context.Background(), ChatRequest{Model: "llama3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	if delta, err := stream.Recv(); err != nil || delta.Content != "Hi" {
+		t.Fatalf("first delta = %+v, %v; want Hi", delta, err)
+	}
+	_, err = stream.Recv()
+	var providerErr *ProviderError
+	if !errors.As(err, &providerErr) || !strings.Contains(providerErr.Message, "unexpectedly stopped") || !shouldRetry(err) {
+		t.Errorf("err = %v, want a retryable ProviderError with Ollama's message", err)
+	}
+}
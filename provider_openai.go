@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func init() {
+	registerProviderType("openai", newOpenAIProvider)
+}
+
+// openAIProvider talks to the OpenAI API or any OpenAI-compatible endpoint
+type openAIProvider struct {
+	client      *openai.Client
+	streamUsage bool
+}
+
+func newOpenAIProvider(name string, config ProviderConfig) (Provider, error) {
+	clientConfig := openai.DefaultConfig(config.APIKey)
+	if config.Endpoint != "" {
+		clientConfig.BaseURL = config.Endpoint
+	}
+	clientConfig.HTTPClient = retryAfterRecorder{client: reasoningFieldMapper{client: &http.Client{}}}
+
+	streamUsage := config.Endpoint == "" || strings.Contains(config.Endpoint, "api.openai.com")
+	if config.StreamUsage != nil {
+		streamUsage = *config.StreamUsage
+	}
+	return &openAIProvider{client: openai.NewClientWithConfig(clientConfig), streamUsage: streamUsage}, nil
+}
+
+// completionRequest converts a chat request into the go-openai request type
+func (p *openAIProvider) completionRequest(request ChatRequest) openai.ChatCompletionRequest {
+	// Tool results keep the tool name for display, but the API does not accept it
+	messages := make([]openai.ChatCompletionMessage, len(request.Messages))
+	for i, msg := range request.Messages {
+		if msg.Role == openai.ChatMessageRoleTool {
+			msg.Name = ""
+		}
+		messages[i] = msg
+	}
+
+	completionRequest := openai.ChatCompletionRequest{
+		Model:            request.Model,
+		Messages:         messages,
+		Tools:            request.Tools,
+		ResponseFormat:   request.ResponseFormat,
+		TopP:             request.Sampling.TopP,
+		Stop:             request.Sampling.Stop,
+		Seed:             request.Sampling.Seed,
+		PresencePenalty:  request.Sampling.PresencePenalty,
+		FrequencyPenalty: request.Sampling.FrequencyPenalty,
+		ReasoningEffort:  request.Sampling.ReasoningEffort,
+	}
+
+	// go-openai leaves out a temperature of 0, so it is sent as the smallest value
+	// above it. Reasoning models only take their default, which 0 leaves in place.
+	if request.Temperature != nil {
+		completionRequest.Temperature = *request.Temperature
+		if completionRequest.Temperature == 0 && !isReasoningModel(request.Model) {
+			completionRequest.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+
+	// Reasoning models reject max_tokens in favour of max_completion_tokens
+	if request.Sampling.ReasoningEffort != "" || isReasoningModel(request.Model) {
+		completionRequest.MaxCompletionTokens = request.Sampling.MaxTokens
+	} else {
+		completionRequest.MaxTokens = request.Sampling.MaxTokens
+	}
+	return completionRequest
+}
+
+// isReasoningModel reports whether a model is one of OpenAI's o-series reasoning models
+func isReasoningModel(model string) bool {
+	return len(model) >= 2 && model[0] == 'o' && model[1] >= '1' && model[1] <= '9'
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, request ChatRequest) (ChatResponse, error) {
+	response, err := p.client.CreateChatCompletion(ctx, p.completionRequest(request))
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	if len(response.Choices) == 0 {
+		return ChatResponse{}, errors.New("provider returned no choices")
+	}
+	return ChatResponse{
+		Content:      response.Choices[0].Message.Content,
+		ToolCalls:    response.Choices[0].Message.ToolCalls,
+		FinishReason: string(response.Choices[0].FinishReason),
+		Usage:        openAIUsage(&response.Usage),
+	}, nil
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, request ChatRequest) (ChatStream, error) {
+	completionRequest := p.completionRequest(request)
+	completionRequest.Stream = true
+	if p.streamUsage {
+		completionRequest.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+
+	stream, err := p.client.CreateChatCompletionStream(ctx, completionRequest)
+	if err != nil {
+		return nil, err
+	}
+	return &openAIStream{stream: stream}, nil
+}
+
+func (p *openAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	list, err := p.client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(list.Models))
+	for _, model := range list.Models {
+		names = append(names, model.ID)
+	}
+	return names, nil
+}
+
+// openAIUsage converts go-openai usage, returning nil when none was reported
+func openAIUsage(usage *openai.Usage) *TokenUsage {
+	if usage == nil || usage.TotalTokens == 0 {
+		return nil
+	}
+	return &TokenUsage{PromptTokens: usage.PromptTokens, CompletionTokens: usage.CompletionTokens}
+}
+
+// openAIStream adapts a go-openai stream to the ChatStream interface
+type openAIStream struct {
+	stream *openai.ChatCompletionStream
+}
+
+func (s *openAIStream) Recv() (ChatDelta, error) {
+	for {
+		response, err := s.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return ChatDelta{}, io.EOF
+		}
+		if err != nil {
+			return ChatDelta{}, err
+		}
+		if len(response.Choices) == 0 {
+			// The usage chunk requested through stream options has no choices
+			if response.Usage != nil {
+				return ChatDelta{Usage: openAIUsage(response.Usage)}, nil
+			}
+			continue
+		}
+		return ChatDelta{
+			Content:      response.Choices[0].Delta.Content,
+			ToolCalls:    response.Choices[0].Delta.ToolCalls,
+			FinishReason: string(response.Choices[0].FinishReason),
+			Usage:        openAIUsage(response.Usage),
+		}, nil
+	}
+}
+
+func (s *openAIStream) Close() error {
+	return s.stream.Close()
+}
+
+// reasoningFieldMapper wraps an HTTP client so that the reasoning_content (or
+// reasoning) field that OpenAI-compatible servers such as DeepSeek and vLLM send
+// in stream chunks, and that go-openai drops, reaches the reply as a <think> block
+type reasoningFieldMapper struct {
+	client openai.HTTPDoer
+}
+
+func (m reasoningFieldMapper) Do(req *http.Request) (*http.Response, error) {
+	resp, err := m.client.Do(req)
+	if err != nil || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp, err
+	}
+	resp.Body = &reasoningStreamBody{source: bufio.NewReader(resp.Body), body: resp.Body}
+	return resp, nil
+}
+
+// reasoningStreamBody rewrites stream chunks line by line, moving the reasoning
+// field into the content between <think> tags
+type reasoningStreamBody struct {
+	source   *bufio.Reader
+	body     io.Closer
+	pending  []byte
+	err      error
+	thinking bool
+}
+
+func (b *reasoningStreamBody) Read(p []byte) (int, error) {
+	for len(b.pending) == 0 && b.err == nil {
+		var line []byte
+		line, b.err = b.source.ReadBytes('\n')
+		b.pending = b.rewrite(line)
+	}
+	if len(b.pending) == 0 {
+		return 0, b.err
+	}
+	n := copy(p, b.pending)
+	b.pending = b.pending[n:]
+	return n, nil
+}
+
+func (b *reasoningStreamBody) Close() error {
+	return b.body.Close()
+}
+
+// rewrite returns a stream line with any reasoning in it moved into the content
+func (b *reasoningStreamBody) rewrite(line []byte) []byte {
+	payload, found := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+	if !found {
+		return line
+	}
+
+	var chunk map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if decoder.Decode(&chunk) != nil {
+		return line
+	}
+	choices, _ := chunk["choices"].([]any)
+	if len(choices) == 0 {
+		return line
+	}
+	choice, _ := choices[0].(map[string]any)
+	delta, _ := choice["delta"].(map[string]any)
+	if delta == nil {
+		return line
+	}
+
+	reasoning, _ := delta["reasoning_content"].(string)
+	if reasoning == "" {
+		reasoning, _ = delta["reasoning"].(string)
+	}
+	content, _ := delta["content"].(string)
+	answering := content != "" || delta["tool_calls"] != nil || choice["finish_reason"] != nil
+	if reasoning == "" && !(b.thinking && answering) {
+		return line
+	}
+
+	var text strings.Builder
+	if reasoning != "" {
+		if !b.thinking {
+			text.WriteString(thinkOpen)
+			b.thinking = true
+		}
+		text.WriteString(reasoning)
+	}
+	if b.thinking && answering {
+		text.WriteString(thinkClose)
+		b.thinking = false
+	}
+	text.WriteString(content)
+
+	delta["content"] = text.String()
+	delete(delta, "reasoning_content")
+	delete(delta, "reasoning")
+	rewritten, err := json.Marshal(chunk)
+	if err != nil {
+		return line
+	}
+	return append(append([]byte("data: "), rewritten...), '\n')
+}
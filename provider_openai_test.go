@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// openAIStandIn serves canned chat completion stream chunks and records the last request
+type openAIStandIn struct {
+	body   map[string]any
+	chunks []string
+}
+
+func (s *openAIStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.body = nil
+	if err := json.NewDecoder(r.Body).Decode(&s.body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("content-type", "text/event-stream")
+	for _, chunk := range s.chunks {
+		fmt.Fprintf(w, "data: %s\n\n", chunk)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func newOpenAIStandIn(t *testing.T, config ProviderConfig, chunks ...string) (*openAIStandIn, Provider) {
+	t.Helper()
+	standIn := &openAIStandIn{chunks: chunks}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	config.Endpoint = server.URL
+	provider, err := newOpenAIProvider("compatible", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return standIn, provider
+}
+
+// streamContent reads a whole stream and returns the content of its deltas
+func streamContent(t *testing.T, provider Provider, request ChatRequest) string {
+	t.Helper()
+	stream, err := provider.Stream(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	var content strings.Builder
+	for {
+		delta, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return content.String()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content.WriteString(delta.Content)
+	}
+}
+
+var openAITestRequest = ChatRequest{
+	Model:    "deepseek-reasoner",
+	Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Hi"}},
+}
+
+func TestOpenAIStreamReasoningField(t *testing.T) {
+	_, provider := newOpenAIStandIn(t, ProviderConfig{},
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+		`{"choices":[{"index":0,"delta":{"reasoning_content":"The user "}}]}`,
+		`{"choices":[{"index":0,"delta":{"reasoning_content":"says hi."}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"Hello!"}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	)
+
+	content := streamContent(t, provider, openAITestRequest)
+	reasoning, answer := splitReasoning(content)
+	if reasoning != "The user says hi." || answer != "Hello!" {
+		t.Errorf("reasoning, answer = %q, %q; want the reasoning field apart from the content", reasoning, answer)
+	}
+}
+
+func TestOpenAIStreamReasoningAlias(t *testing.T) {
+	_, provider := newOpenAIStandIn(t, ProviderConfig{},
+		`{"choices":[{"index":0,"delta":{"reasoning":"Thinking."}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	)
+
+	if content := streamContent(t, provider, openAITestRequest); content != "<think>Thinking.</think>" {
+		t.Errorf("content = %q, want the reasoning closed at the finish", content)
+	}
+}
+
+func TestOpenAIStreamUsageOption(t *testing.T) {
+	chunk := `{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`
+
+	standIn, provider := newOpenAIStandIn(t, ProviderConfig{}, chunk)
+	streamContent(t, provider, openAITestRequest)
+	if _, sent := standIn.body["stream_options"]; sent {
+		t.Error("stream_options sent to an OpenAI-compatible endpoint without stream_usage")
+	}
+
+	enabled := true
+	standIn, provider = newOpenAIStandIn(t, ProviderConfig{StreamUsage: &enabled}, chunk)
+	streamContent(t, provider, openAITestRequest)
+	if options, _ := standIn.body["stream_options"].(map[string]any); options["include_usage"] != true {
+		t.Errorf("stream_options = %v, want include_usage with stream_usage: true", standIn.body["stream_options"])
+	}
+}
+
+func TestOpenAIZeroTemperature(t *testing.T) {
+	chunk := `{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`
+	standIn, provider := newOpenAIStandIn(t, ProviderConfig{}, chunk)
+
+	request := openAITestRequest
+	request.Model = "gpt-4o"
+	request.Temperature = new(float32)
+	streamContent(t, provider, request)
+	if got, ok := standIn.body["temperature"].(float64); !ok || got <= 0 || got > 1e-30 {
+		t.Errorf("temperature = %v, want it sent as the smallest value above 0", standIn.body["temperature"])
+	}
+
+	request.Temperature = nil
+	streamContent(t, provider, request)
+	if got, sent := standIn.body["temperature"]; sent {
+		t.Errorf("temperature = %v, want the provider default without one", got)
+	}
+
+	request.Model = "o3-mini"
+	request.Temperature = new(float32)
+	streamContent(t, provider, request)
+	if got, sent := standIn.body["temperature"]; sent {
+		t.Errorf("temperature = %v, want a reasoning model's default", got)
+	}
+}
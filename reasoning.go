@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	thinkOpen  = "<think>"
+	thinkClose = "</think>"
+)
+
+// showReasoning expands the reasoning sections in the chat log
+var showReasoning bool
+
+// splitReasoning separates the <think> blocks of a reply from the answer. An
+// unclosed block is reasoning still being streamed, and a closing tag without an
+// opening one (some chat templates put <think> in the prompt) ends the reasoning.
+func splitReasoning(text string) (reasoning, answer string) {
+	if !strings.Contains(text, thinkOpen) && !strings.Contains(text, thinkClose) {
+		return "", text
+	}
+
+	if before, after, found := strings.Cut(text, thinkClose); found && !strings.Contains(before, thinkOpen) {
+		reasoning, text = before, after
+	}
+
+	var thoughts, rest []string
+	if strings.TrimSpace(reasoning) != "" {
+		thoughts = append(thoughts, strings.TrimSpace(reasoning))
+	}
+	for {
+		before, after, found := strings.Cut(text, thinkOpen)
+		rest = append(rest, before)
+		if !found {
+			break
+		}
+		thought, remaining, closed := strings.Cut(after, thinkClose)
+		if thought = strings.TrimSpace(thought); thought != "" {
+			thoughts = append(thoughts, thought)
+		}
+		if !closed {
+			break
+		}
+		text = remaining
+	}
+
+	return strings.Join(thoughts, "\n\n"), strings.TrimSpace(strings.Join(rest, ""))
+}
+
+// withoutReasoning returns the history with reasoning removed from assistant
+// messages, which conversations saved before it was split out still contain
+func withoutReasoning(history []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	stripped, copied := history, false
+	for i, msg := range history {
+		if msg.Role != openai.ChatMessageRoleAssistant || !strings.Contains(msg.Content, thinkClose) {
+			continue
+		}
+		if !copied {
+			stripped, copied = append([]openai.ChatCompletionMessage{}, history...), true
+		}
+		_, stripped[i].Content = splitReasoning(msg.Content)
+	}
+	return stripped
+}
+
+// printReasoning prints a reply's reasoning as a dimmed section, or a one-line
+// summary of it while reasoning is collapsed
+func printReasoning(v *gocui.View, reasoning string) {
+	if reasoning == "" {
+		return
+	}
+
+	fmt.Fprintln(v)
+	words := len(strings.Fields(reasoning))
+	if !showReasoning {
+		fmt.Fprintf(v, "  \033[2m▸ reasoning (%d words, r to expand)\033[0m\n", words)
+		return
+	}
+
+	fmt.Fprintf(v, "  \033[2m▾ reasoning (%d words, r to collapse)\033[0m\n", words)
+	width, _ := v.Size()
+	for _, line := range strings.Split(formatMessage(reasoning, width-12, ""), "\n") {
+		fmt.Fprintf(v, "  \033[2m│ %s\033[0m\n", line)
+	}
+}
+
+// toggleReasoning expands or collapses the reasoning sections in the chat log
+func toggleReasoning(g *gocui.Gui, v *gocui.View) error {
+	showReasoning = !showReasoning
+	if streaming {
+		renderStreamingReply(v)
+	} else {
+		renderChatLog(v)
+	}
+	return nil
+}
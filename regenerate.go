@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Regenerate the last assistant response with the provider and model that wrote it
+func regenerateResponse(g *gocui.Gui, v *gocui.View) error {
+	route := lastReplyRoute()
+	route.model = currentConvo.Params.apply(route.model)
+	return regenerate(g, route)
+}
+
+// regenerate reissues the request for the last assistant response. The current
+// response is kept as an alternate version.
+func regenerate(g *gocui.Gui, route turnRoute) error {
+	if streaming {
+		return nil
+	}
+
+	last := len(currentConvo.ChatHistory) - 1
+	if last < 1 || currentConvo.ChatHistory[last].Role != openai.ChatMessageRoleAssistant {
+		setStatus(g, "Nothing to regenerate")
+		return nil
+	}
+
+	if err := startCompletion(g, route, true); err != nil {
+		setStatus(g, "\033[31m"+describeError(err)+"\033[0m")
+	}
+	return nil
+}
+
+// regenerateCommand handles /regen, optionally overriding temperature and model.
+// Without a model the reply is regenerated by the backend that wrote it.
+func regenerateCommand(g *gocui.Gui, args []string) error {
+	options, err := parseOptions(args)
+	if err != nil {
+		setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+		return nil
+	}
+
+	route := lastReplyRoute()
+	if name, ok := options["model"]; ok {
+		// A model that isn't configured gets the active model's settings
+		route = turnRoute{provider: providers[activeProvider], model: models[activeModel]}
+		route.model.Name = name
+		for _, configured := range models {
+			if configured.Name == name {
+				route.model = configured
+			}
+		}
+	}
+	model := currentConvo.Params.apply(route.model)
+	if value, ok := options["temp"]; ok {
+		var override SamplingOverride
+		if err := override.set("temp", value); err != nil {
+			setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+			return nil
+		}
+		model = override.apply(model)
+	}
+	if err := config.Providers[route.provider].validateSampling(model.Temperature, model.SamplingParams); err != nil {
+		setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+		return nil
+	}
+	route.model = model
+
+	return regenerate(g, route)
+}
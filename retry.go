@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultMaxRetries    = 3
+	defaultMaxRetryDelay = 30 * time.Second
+	baseRetryDelay       = time.Second
+)
+
+// retryPolicy controls how often and how long a failed request is retried
+type retryPolicy struct {
+	MaxRetries int
+	MaxDelay   time.Duration
+}
+
+// retryPolicy returns the provider's retry settings with defaults filled in
+func (p ProviderConfig) retryPolicy() retryPolicy {
+	policy := retryPolicy{MaxRetries: defaultMaxRetries, MaxDelay: defaultMaxRetryDelay}
+	if p.MaxRetries != nil {
+		policy.MaxRetries = max(*p.MaxRetries, 0)
+	}
+	if p.MaxRetryDelay > 0 {
+		policy.MaxDelay = p.MaxRetryDelay
+	}
+	return policy
+}
+
+// shouldRetry reports whether an error is a rate limit or a transient failure
+func shouldRetry(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if status := statusCode(err); status != 0 {
+		return status == http.StatusTooManyRequests || status >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// backoffDelay returns how long to wait before the given retry attempt (starting at 0).
+// A Retry-After hint from the provider takes precedence over exponential backoff,
+// unless it is longer than maxDelay, in which case ok is false and the request
+// should not be retried.
+func backoffDelay(attempt int, maxDelay, retryAfter time.Duration) (delay time.Duration, ok bool) {
+	if retryAfter > maxDelay {
+		return 0, false
+	}
+	if retryAfter > 0 {
+		return retryAfter, true
+	}
+
+	delay = baseRetryDelay << attempt
+	if delay <= 0 || delay > maxDelay {
+		delay = maxDelay
+	}
+
+	// Full jitter over the upper half of the window keeps clients sharing a key apart
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)), true
+}
+
+// waitForRetry sleeps before a retry while counting down in the command bar
+func waitForRetry(ctx context.Context, g *gocui.Gui, delay time.Duration, attempt, maxRetries int, err error) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	deadline := time.Now().Add(delay)
+	for {
+		remaining := time.Until(deadline).Round(time.Second)
+		if remaining <= 0 {
+			return nil
+		}
+
+		status := fmt.Sprintf("\033[33m%s\033[0m, retrying in %s (attempt %d/%d, Esc to cancel)",
+			describeError(err), remaining, attempt, maxRetries)
+		updateUI(g, func(g *gocui.Gui) error {
+			setStatus(g, status)
+			return nil
+		})
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// retryHint holds the Retry-After delay sent with the last failed response of a request
+type retryHint struct {
+	mu    sync.Mutex
+	after time.Duration
+}
+
+type retryHintKey struct{}
+
+// withRetryHint attaches a retry hint to the context so the HTTP client can fill it in
+func withRetryHint(ctx context.Context) (context.Context, *retryHint) {
+	hint := &retryHint{}
+	return context.WithValue(ctx, retryHintKey{}, hint), hint
+}
+
+func (h *retryHint) set(after time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.after = after
+}
+
+// take returns the recorded delay and clears it for the next attempt
+func (h *retryHint) take() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	after := h.after
+	h.after = 0
+	return after
+}
+
+// retryAfterRecorder wraps an HTTP client and records Retry-After headers
+// into the retry hint carried by the request context
+type retryAfterRecorder struct {
+	client openai.HTTPDoer
+}
+
+func (r retryAfterRecorder) Do(req *http.Request) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if hint, ok := req.Context().Value(retryHintKey{}).(*retryHint); ok {
+		hint.set(parseRetryAfter(resp.Header.Get("Retry-After")))
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
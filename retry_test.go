@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	maxDelay := 30 * time.Second
+	for attempt, window := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, maxDelay, maxDelay} {
+		// Jitter keeps each delay in the upper half of the window
+		for range 50 {
+			delay, ok := backoffDelay(attempt, maxDelay, 0)
+			if !ok || delay < window/2 || delay > window {
+				t.Fatalf("attempt %d: delay = %s (ok: %t), want between %s and %s", attempt, delay, ok, window/2, window)
+			}
+		}
+	}
+
+	if delay, ok := backoffDelay(100, maxDelay, 0); !ok || delay < maxDelay/2 || delay > maxDelay {
+		t.Errorf("attempt 100: delay = %s (ok: %t), want it capped at max_retry_delay", delay, ok)
+	}
+	if delay, ok := backoffDelay(0, maxDelay, 12*time.Second); !ok || delay != 12*time.Second {
+		t.Errorf("Retry-After 12s: delay = %s (ok: %t), want the hint as is", delay, ok)
+	}
+	if delay, ok := backoffDelay(0, maxDelay, maxDelay); !ok || delay != maxDelay {
+		t.Errorf("Retry-After at max_retry_delay: delay = %s (ok: %t), want it waited for", delay, ok)
+	}
+	if _, ok := backoffDelay(0, maxDelay, time.Minute); ok {
+		t.Error("Retry-After above max_retry_delay should not be retried")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"7", 7 * time.Second},
+		{"-3", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	} {
+		if got := parseRetryAfter(test.value); got != test.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+
+	date := time.Now().Add(20 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 18*time.Second || got > 20*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %s, want about 20s", date, got)
+	}
+}
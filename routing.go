@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// RouteRule sends turns that meet all of its conditions to another provider/model.
+// Rules are checked in order and the first match wins.
+type RouteRule struct {
+	Name   string `yaml:"name,omitempty"` // shown in the status bar, defaults to "rule N"
+	Target string `yaml:"target"`         // provider/model
+
+	MinLength   int    `yaml:"min_length,omitempty"`  // input of at least this many characters
+	MaxLength   int    `yaml:"max_length,omitempty"`  // input of at most this many characters
+	Match       string `yaml:"match,omitempty"`       // regular expression the input matches
+	Attachments *bool  `yaml:"attachments,omitempty"` // whether files or images are attached
+	Code        *bool  `yaml:"code,omitempty"`        // whether the input looks like it contains code
+
+	pattern *regexp.Regexp
+}
+
+// turnRoute is the provider and model chosen for a turn, and why
+type turnRoute struct {
+	provider string
+	model    ModelConfig
+	reason   string // empty when the turn goes to the active model
+	input    string // the input as typed, with any @model prefix, for resending
+}
+
+func (r turnRoute) String() string {
+	return r.provider + "/" + r.model.Name
+}
+
+// routeStatus describes a routing decision for the status bar
+func (job *completionJob) routeStatus() string {
+	return fmt.Sprintf("\033[35mRouted to %s/%s (%s)\033[0m", job.providerName, job.model.Name, job.route)
+}
+
+// modelPrefix matches an explicit "@model" or "@provider/model" at the start of the input
+var modelPrefix = regexp.MustCompile(`^@(\S+)\s+`)
+
+// validateRouting checks every routing rule's target and compiles its pattern
+func (c *Config) validateRouting() error {
+	for i := range c.Routing {
+		rule := &c.Routing[i]
+		if err := c.checkBackend(rule.Target); err != nil {
+			return fmt.Errorf("routing %s: %w", rule.label(i), err)
+		}
+		if rule.MaxLength > 0 && rule.MinLength > rule.MaxLength {
+			return fmt.Errorf("routing %s: min_length is greater than max_length", rule.label(i))
+		}
+		if rule.Match != "" {
+			pattern, err := regexp.Compile(rule.Match)
+			if err != nil {
+				return fmt.Errorf("routing %s: invalid match: %w", rule.label(i), err)
+			}
+			rule.pattern = pattern
+		}
+	}
+	return nil
+}
+
+// label names a rule for errors and the status bar
+func (r RouteRule) label(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("rule %d", index+1)
+}
+
+// matches reports whether a turn meets all of the rule's conditions
+func (r RouteRule) matches(input string, attachments []attachment) bool {
+	length := utf8.RuneCountInString(input)
+	switch {
+	case r.MinLength > 0 && length < r.MinLength:
+		return false
+	case r.MaxLength > 0 && length > r.MaxLength:
+		return false
+	case r.pattern != nil && !r.pattern.MatchString(input):
+		return false
+	case r.Attachments != nil && *r.Attachments != (len(attachments) > 0):
+		return false
+	case r.Code != nil && *r.Code != looksLikeCode(input):
+		return false
+	}
+	return true
+}
+
+// routeTurn chooses the provider and model for a turn: an @model prefix first,
+// then the first matching routing rule, then the active model. It returns the
+// input without the prefix.
+func routeTurn(input string, attachments []attachment) (turnRoute, string) {
+	if match := modelPrefix.FindStringSubmatch(input); match != nil {
+		if target, ok := findRouteTarget(match[1]); ok {
+			return turnRoute{provider: target.provider, model: target.model, reason: "@" + match[1], input: input}, input[len(match[0]):]
+		}
+	}
+
+	for i, rule := range config.Routing {
+		if !rule.matches(input, attachments) {
+			continue
+		}
+		target, err := parseCompareTarget(rule.Target)
+		if err != nil {
+			continue
+		}
+		return turnRoute{provider: target.provider, model: target.model, reason: rule.label(i), input: input}, input
+	}
+
+	return turnRoute{provider: providers[activeProvider], model: models[activeModel], input: input}, input
+}
+
+// lastReplyRoute returns the provider and model that wrote the last reply, so it
+// is regenerated by the same backend, or the active model if that is unknown
+func lastReplyRoute() turnRoute {
+	meta := currentConvo.MetaAt(len(currentConvo.ChatHistory) - 1)
+	if meta.Provider == "" || meta.Model == "" {
+		return turnRoute{provider: providers[activeProvider], model: models[activeModel]}
+	}
+	if meta.Provider == providers[activeProvider] {
+		for _, model := range models {
+			if model.Name == meta.Model {
+				return turnRoute{provider: meta.Provider, model: model}
+			}
+		}
+	}
+	target, err := parseCompareTarget(meta.Provider + "/" + meta.Model)
+	if err != nil {
+		return turnRoute{provider: providers[activeProvider], model: models[activeModel]}
+	}
+	return turnRoute{provider: target.provider, model: target.model}
+}
+
+// findRouteTarget resolves the name after an @: provider/model, a model of the
+// active provider, or a model configured for any provider
+func findRouteTarget(name string) (compareTarget, bool) {
+	if strings.Contains(name, "/") {
+		target, err := parseCompareTarget(name)
+		return target, err == nil
+	}
+
+	for _, model := range models {
+		if model.Name == name {
+			return compareTarget{provider: providers[activeProvider], model: model}, true
+		}
+	}
+	for _, providerName := range config.GetAllProviders() {
+		if model, err := config.GetModelConfig(providerName, name); err == nil {
+			return compareTarget{provider: providerName, model: *model}, true
+		}
+	}
+	return compareTarget{}, false
+}
+
+// codeLine matches lines that are typical of source code rather than prose
+var codeLine = regexp.MustCompile(`^(\t| {4})\S|[;{}]\s*$|^\s*(func|def|class|import|package|return|#include)\b`)
+
+// looksLikeCode reports whether input contains a fenced code block or at least
+// two lines that look like source code
+func looksLikeCode(input string) bool {
+	if strings.Contains(input, "```") {
+		return true
+	}
+	count := 0
+	for _, line := range strings.Split(input, "\n") {
+		if codeLine.MatchString(line) {
+			count++
+		}
+	}
+	return count >= 2
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// loadRoutingConfig makes a config with routing rules the active one, with
+// openai/gpt-4o as the active model
+func loadRoutingConfig(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(path, []byte(`openai:
+  models:
+    - name: "gpt-4o"
+    - name: "gpt-4o-mini"
+ollama:
+  type: "ollama"
+  models:
+    - name: "llama3"
+anthropic:
+  type: "anthropic"
+  models:
+    - name: "claude"
+routing:
+  - name: "files"
+    target: "openai/gpt-4o-mini"
+    attachments: true
+  - name: "code"
+    target: "anthropic/claude"
+    code: true
+  - name: "short"
+    target: "ollama/llama3"
+    max_length: 20
+  - target: "openai/gpt-4o-mini"
+    min_length: 200
+  - name: "why"
+    target: "ollama/llama3"
+    match: "(?i)\\bwhy\\b"
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	savedConfig, savedProviders, savedModels := config, providers, models
+	t.Cleanup(func() {
+		config, providers, models = savedConfig, savedProviders, savedModels
+		activeProvider, activeModel = 0, 0
+	})
+	config, err = LoadConfigFromPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	providers = config.GetAllProviders()
+	activeProvider, activeModel = slices.Index(providers, "openai"), 0
+	models = config.Providers["openai"].Models
+}
+
+func TestRouteTurn(t *testing.T) {
+	loadRoutingConfig(t)
+	file := []attachment{{name: "notes.txt"}}
+	code := "func main() {\n\tfmt.Println(\"hi\")\n}"
+	longQuestion := "Could you explain in a few sentences why the sky looks blue?"
+
+	for _, test := range []struct {
+		name        string
+		input       string
+		attachments []attachment
+		route       string
+		reason      string
+		text        string // the input sent on, empty when it is unchanged
+	}{
+		{"model prefix", "@gpt-4o-mini hello there", nil, "openai/gpt-4o-mini", "@gpt-4o-mini", "hello there"},
+		{"model of another provider", "@claude hi", nil, "anthropic/claude", "@claude", "hi"},
+		{"provider and model", "@ollama/qwen3 hi", nil, "ollama/qwen3", "@ollama/qwen3", "hi"},
+		{"prefix before the rules", "@gpt-4o " + code, nil, "openai/gpt-4o", "@gpt-4o", code},
+		{"unknown model", "@nobody hi", nil, "ollama/llama3", "short", ""},
+		{"attachments", "summarize this", file, "openai/gpt-4o-mini", "files", ""},
+		{"first rule wins", code, file, "openai/gpt-4o-mini", "files", ""},
+		{"code", code, nil, "anthropic/claude", "code", ""},
+		{"max length", "why?", nil, "ollama/llama3", "short", ""},
+		{"min length", strings.Repeat("tell me more ", 20), nil, "openai/gpt-4o-mini", "rule 4", ""},
+		{"regex", longQuestion, nil, "ollama/llama3", "why", ""},
+		{"no rule", "Could you explain in a few sentences how rainbows form?", nil, "openai/gpt-4o", "", ""},
+	} {
+		route, text := routeTurn(test.input, test.attachments)
+		if test.text == "" {
+			test.text = test.input
+		}
+		if route.String() != test.route || route.reason != test.reason || text != test.text || route.input != test.input {
+			t.Errorf("%s: routed to %s (%q) with %q, want %s (%q) with %q", test.name, route, route.reason, text, test.route, test.reason, test.text)
+		}
+	}
+}
+
+func TestLooksLikeCode(t *testing.T) {
+	for _, test := range []struct {
+		input string
+		want  bool
+	}{
+		{"What does this do?\n```\nls -la\n```", true},
+		{"func main() {\n\treturn\n}", true},
+		{"import os\ndef main():\n    print(1)", true},
+		{"x := 1;\ny := 2;", true},
+		{"#include <stdio.h>\nint main() {", true},
+		{"Just one line of code;", false},
+		{"Why is the sky blue?\nAnd why are sunsets red?", false},
+		{"My shopping list:\n- eggs\n- milk", false},
+	} {
+		if got := looksLikeCode(test.input); got != test.want {
+			t.Errorf("looksLikeCode(%q) = %t, want %t", test.input, got, test.want)
+		}
+	}
+}
+
+func TestValidateRouting(t *testing.T) {
+	for _, test := range []struct {
+		rule RouteRule
+		err  string
+	}{
+		{RouteRule{Target: "openai"}, "must be provider/model"},
+		{RouteRule{Target: "nowhere/model"}, "provider not found"},
+		{RouteRule{Target: "openai/gpt-4o", MinLength: 10, MaxLength: 5}, "min_length is greater than max_length"},
+		{RouteRule{Target: "openai/gpt-4o", Match: "("}, "invalid match"},
+	} {
+		c := &Config{Providers: map[string]ProviderConfig{"openai": {}}, Routing: []RouteRule{test.rule}}
+		if err := c.validateRouting(); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%+v: error = %v, want %q", test.rule, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+// maxStopSequences is the most stop sequences the OpenAI API accepts
+const maxStopSequences = 4
+
+// reasoningEfforts are the accepted reasoning_effort values for o-series models
+var reasoningEfforts = []string{"low", "medium", "high"}
+
+// SamplingParams are a model's optional generation settings. Zero values leave
+// the provider's default in place.
+type SamplingParams struct {
+	MaxTokens        int      `yaml:"max_tokens,omitempty"`
+	TopP             float32  `yaml:"top_p,omitempty"`
+	Stop             []string `yaml:"stop,omitempty"`
+	Seed             *int     `yaml:"seed,omitempty"`
+	PresencePenalty  float32  `yaml:"presence_penalty,omitempty"`
+	FrequencyPenalty float32  `yaml:"frequency_penalty,omitempty"`
+	ReasoningEffort  string   `yaml:"reasoning_effort,omitempty"` // o-series only: low, medium or high
+}
+
+// maxTemperature returns the highest temperature the provider's API accepts
+func (p ProviderConfig) maxTemperature() float32 {
+	if p.Type == "anthropic" {
+		return 1
+	}
+	return 2
+}
+
+// validateSampling checks a temperature and sampling parameters against the
+// ranges the provider accepts
+func (p ProviderConfig) validateSampling(temperature *float32, params SamplingParams) error {
+	switch {
+	case temperature != nil && (*temperature < 0 || *temperature > p.maxTemperature()):
+		return fmt.Errorf("temp must be between 0 and %g, got %g", p.maxTemperature(), *temperature)
+	case params.MaxTokens < 0:
+		return fmt.Errorf("max_tokens must not be negative, got %d", params.MaxTokens)
+	case params.TopP < 0 || params.TopP > 1:
+		return fmt.Errorf("top_p must be between 0 and 1, got %g", params.TopP)
+	case len(params.Stop) > maxStopSequences:
+		return fmt.Errorf("at most %d stop sequences are allowed, got %d", maxStopSequences, len(params.Stop))
+	case params.PresencePenalty < -2 || params.PresencePenalty > 2:
+		return fmt.Errorf("presence_penalty must be between -2 and 2, got %g", params.PresencePenalty)
+	case params.FrequencyPenalty < -2 || params.FrequencyPenalty > 2:
+		return fmt.Errorf("frequency_penalty must be between -2 and 2, got %g", params.FrequencyPenalty)
+	}
+	if params.ReasoningEffort != "" && !slices.Contains(reasoningEfforts, params.ReasoningEffort) {
+		return fmt.Errorf("reasoning_effort must be one of %s, got %q", strings.Join(reasoningEfforts, ", "), params.ReasoningEffort)
+	}
+	return nil
+}
+
+// validate checks the sampling parameters and response formats of every
+// configured model
+func (c *Config) validate() error {
+	if err := c.validateFallback(); err != nil {
+		return err
+	}
+	if err := c.validateRouting(); err != nil {
+		return err
+	}
+	for _, providerName := range c.GetAllProviders() {
+		providerConfig := c.Providers[providerName]
+		models := providerConfig.Models
+		for i, model := range models {
+			if err := providerConfig.validateSampling(model.Temperature, model.SamplingParams); err != nil {
+				return fmt.Errorf("provider %s, model %s: %w", providerName, model.Name, err)
+			}
+			// A bad schema only turns off that model's structured output
+			if err := models[i].loadResponseFormat(); err != nil {
+				c.warnings = append(c.warnings, fmt.Sprintf("provider %s: %v; replies won't be structured", providerName, err))
+			}
+		}
+	}
+	return nil
+}
+
+// SamplingOverride holds the sampling parameters a conversation sets in place of
+// its model's defaults. Nil fields keep the model's value. The seed and
+// reasoning_effort can also be set to "none", which sends no value even if the
+// model has one: NoSeed, and an empty ReasoningEffort.
+type SamplingOverride struct {
+	Temperature      *float32 `json:"temperature,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	NoSeed           bool     `json:"no_seed,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+	ReasoningEffort  *string  `json:"reasoning_effort,omitempty"`
+}
+
+// samplingKeys are the parameter names /params accepts, in display order
+var samplingKeys = []string{"temp", "max_tokens", "top_p", "stop", "seed", "presence_penalty", "frequency_penalty", "reasoning_effort"}
+
+// apply returns the model with the override's parameters in place. A nil
+// override returns the model unchanged.
+func (o *SamplingOverride) apply(model ModelConfig) ModelConfig {
+	if o == nil {
+		return model
+	}
+	if o.Temperature != nil {
+		model.Temperature = o.Temperature
+	}
+	if o.MaxTokens != nil {
+		model.MaxTokens = *o.MaxTokens
+	}
+	if o.TopP != nil {
+		model.TopP = *o.TopP
+	}
+	if o.Stop != nil {
+		model.Stop = o.Stop
+	}
+	if o.Seed != nil || o.NoSeed {
+		model.Seed = o.Seed
+	}
+	if o.PresencePenalty != nil {
+		model.PresencePenalty = *o.PresencePenalty
+	}
+	if o.FrequencyPenalty != nil {
+		model.FrequencyPenalty = *o.FrequencyPenalty
+	}
+	if o.ReasoningEffort != nil {
+		model.ReasoningEffort = *o.ReasoningEffort
+	}
+	return model
+}
+
+// set parses value into the named parameter. The value "default" goes back to
+// the model's setting, and "none" sends no seed or reasoning_effort.
+func (o *SamplingOverride) set(key, value string) error {
+	if value == "default" {
+		if !slices.Contains(samplingKeys, key) {
+			return fmt.Errorf("unknown parameter %q", key)
+		}
+		o.unset(key)
+		return nil
+	}
+
+	parseFloat := func() (*float32, error) {
+		number, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+		f := float32(number)
+		return &f, nil
+	}
+	parseInt := func() (*int, error) {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", key, value)
+		}
+		return &number, nil
+	}
+
+	var err error
+	switch key {
+	case "temp":
+		o.Temperature, err = parseFloat()
+	case "max_tokens":
+		o.MaxTokens, err = parseInt()
+	case "top_p":
+		o.TopP, err = parseFloat()
+	case "stop":
+		// Stop sequences are comma separated; an empty value sends none
+		o.Stop = []string{}
+		if value != "" {
+			o.Stop = strings.Split(value, ",")
+		}
+	case "seed":
+		o.Seed, o.NoSeed = nil, value == "none"
+		if !o.NoSeed {
+			o.Seed, err = parseInt()
+		}
+	case "presence_penalty":
+		o.PresencePenalty, err = parseFloat()
+	case "frequency_penalty":
+		o.FrequencyPenalty, err = parseFloat()
+	case "reasoning_effort":
+		if value == "none" {
+			value = ""
+		}
+		o.ReasoningEffort = &value
+	default:
+		err = fmt.Errorf("unknown parameter %q", key)
+	}
+	return err
+}
+
+// unset goes back to the model's value for the named parameter
+func (o *SamplingOverride) unset(key string) {
+	switch key {
+	case "temp":
+		o.Temperature = nil
+	case "max_tokens":
+		o.MaxTokens = nil
+	case "top_p":
+		o.TopP = nil
+	case "stop":
+		o.Stop = nil
+	case "seed":
+		o.Seed, o.NoSeed = nil, false
+	case "presence_penalty":
+		o.PresencePenalty = nil
+	case "frequency_penalty":
+		o.FrequencyPenalty = nil
+	case "reasoning_effort":
+		o.ReasoningEffort = nil
+	}
+}
+
+// overrides reports whether the override sets the named parameter
+func (o *SamplingOverride) overrides(key string) bool {
+	if o == nil {
+		return false
+	}
+	switch key {
+	case "temp":
+		return o.Temperature != nil
+	case "max_tokens":
+		return o.MaxTokens != nil
+	case "top_p":
+		return o.TopP != nil
+	case "stop":
+		return o.Stop != nil
+	case "seed":
+		return o.Seed != nil || o.NoSeed
+	case "presence_penalty":
+		return o.PresencePenalty != nil
+	case "frequency_penalty":
+		return o.FrequencyPenalty != nil
+	case "reasoning_effort":
+		return o.ReasoningEffort != nil
+	}
+	return false
+}
+
+// sendsNone reports whether the override sets the named parameter to "none"
+func (o *SamplingOverride) sendsNone(key string) bool {
+	if o == nil {
+		return false
+	}
+	switch key {
+	case "seed":
+		return o.NoSeed
+	case "reasoning_effort":
+		return o.ReasoningEffort != nil && *o.ReasoningEffort == ""
+	}
+	return false
+}
+
+// isEmpty reports whether the override leaves every parameter at the model's value
+func (o *SamplingOverride) isEmpty() bool {
+	return o == nil || o.Temperature == nil && o.MaxTokens == nil && o.TopP == nil && o.Stop == nil &&
+		o.Seed == nil && !o.NoSeed && o.PresencePenalty == nil && o.FrequencyPenalty == nil && o.ReasoningEffort == nil
+}
+
+// describeSampling lists a model's sampling parameters for the command bar,
+// marking the ones the conversation overrides with *
+func describeSampling(model ModelConfig, override *SamplingOverride) string {
+	values := map[string]string{}
+	if model.Temperature != nil {
+		values["temp"] = strconv.FormatFloat(float64(*model.Temperature), 'g', -1, 32)
+	}
+	if model.MaxTokens > 0 {
+		values["max_tokens"] = strconv.Itoa(model.MaxTokens)
+	}
+	if model.TopP > 0 {
+		values["top_p"] = strconv.FormatFloat(float64(model.TopP), 'g', -1, 32)
+	}
+	if len(model.Stop) > 0 {
+		values["stop"] = strconv.Quote(strings.Join(model.Stop, ","))
+	}
+	if model.Seed != nil {
+		values["seed"] = strconv.Itoa(*model.Seed)
+	}
+	if model.PresencePenalty != 0 {
+		values["presence_penalty"] = strconv.FormatFloat(float64(model.PresencePenalty), 'g', -1, 32)
+	}
+	if model.FrequencyPenalty != 0 {
+		values["frequency_penalty"] = strconv.FormatFloat(float64(model.FrequencyPenalty), 'g', -1, 32)
+	}
+	if model.ReasoningEffort != "" {
+		values["reasoning_effort"] = model.ReasoningEffort
+	}
+
+	parts := make([]string, 0, len(values))
+	for _, key := range samplingKeys {
+		value, ok := values[key]
+		if override.sendsNone(key) {
+			value, ok = "none", true
+		}
+		if !ok {
+			continue
+		}
+		if override.overrides(key) {
+			key = "*" + key
+		}
+		parts = append(parts, key+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// paramsCommand handles /params: with key=value arguments it overrides the
+// model's sampling parameters for this conversation, with "reset" it goes back
+// to the model's defaults, and without arguments it shows the parameters in use
+func paramsCommand(g *gocui.Gui, args []string) error {
+	if len(args) == 1 && args[0] == "reset" {
+		currentConvo.Params = nil
+		setStatus(g, "Using the model's parameters: "+describeSampling(models[activeModel], nil))
+		return saveCurrentConversation()
+	}
+
+	options, err := parseOptions(args)
+	if err != nil {
+		setStatus(g, "\033[31m"+err.Error()+"\033[0m (usage: "+commands["params"].usage+")")
+		return nil
+	}
+
+	override := SamplingOverride{}
+	if currentConvo.Params != nil {
+		override = *currentConvo.Params
+	}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := override.set(key, options[key]); err != nil {
+			setStatus(g, "\033[31m"+err.Error()+"\033[0m (parameters: "+strings.Join(samplingKeys, ", ")+")")
+			return nil
+		}
+	}
+
+	model := override.apply(models[activeModel])
+	if err := config.Providers[providers[activeProvider]].validateSampling(model.Temperature, model.SamplingParams); err != nil {
+		setStatus(g, "\033[31m"+err.Error()+"\033[0m")
+		return nil
+	}
+
+	if len(options) == 0 {
+		setStatus(g, "Parameters: "+describeSampling(model, currentConvo.Params)+" (* set for this chat)")
+		return nil
+	}
+
+	currentConvo.Params = &override
+	if override.isEmpty() {
+		currentConvo.Params = nil
+	}
+	setStatus(g, "Parameters for this chat: "+describeSampling(model, currentConvo.Params))
+	return saveCurrentConversation()
+}
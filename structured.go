@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	responseFormatJSONObject = "json_object"
+	responseFormatJSONSchema = "json_schema"
+)
+
+// responseFormat returns the structured output format configured for the model,
+// or nil for plain text replies
+func (m ModelConfig) responseFormat() *openai.ChatCompletionResponseFormat {
+	return m.format
+}
+
+// loadResponseFormat checks the model's response_format and reads its schema
+// file, so that mistakes are reported when the config is loaded
+func (m *ModelConfig) loadResponseFormat() error {
+	switch m.ResponseFormat {
+	case "":
+		return nil
+	case responseFormatJSONObject:
+		m.format = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+		return nil
+	case responseFormatJSONSchema:
+	default:
+		return fmt.Errorf("model %s: unknown response_format %q (use %s or %s)", m.Name, m.ResponseFormat, responseFormatJSONObject, responseFormatJSONSchema)
+	}
+
+	if m.SchemaFile == "" {
+		return fmt.Errorf("model %s: response_format json_schema needs a schema_file", m.Name)
+	}
+	data, err := os.ReadFile(m.SchemaFile)
+	if err != nil {
+		return fmt.Errorf("model %s: failed to read schema file: %w", m.Name, err)
+	}
+
+	// The file holds either a bare schema or OpenAI's {name, schema, strict} wrapper
+	var wrapper struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+		Strict bool            `json:"strict"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return fmt.Errorf("model %s: failed to parse schema file: %w", m.Name, err)
+	}
+	if wrapper.Schema == nil {
+		wrapper.Schema = data
+		wrapper.Strict = false
+	}
+	if wrapper.Name == "" {
+		wrapper.Name = toolNameInvalid.ReplaceAllString(strings.TrimSuffix(filepath.Base(m.SchemaFile), filepath.Ext(m.SchemaFile)), "_")
+	}
+
+	m.format = &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   wrapper.Name,
+			Schema: wrapper.Schema,
+			Strict: wrapper.Strict,
+		},
+	}
+	return nil
+}
+
+// expandHome replaces a leading ~ in a path with the home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+}
+
+// formatSchema returns the decoded schema of a json_schema format, or nil
+func formatSchema(format *openai.ChatCompletionResponseFormat) any {
+	if format == nil || format.JSONSchema == nil || format.JSONSchema.Schema == nil {
+		return nil
+	}
+	data, err := format.JSONSchema.Schema.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	var schema any
+	if json.Unmarshal(data, &schema) != nil {
+		return nil
+	}
+	return schema
+}
+
+// jsonPayload strips the whitespace and Markdown code fence some models put
+// around JSON replies
+func jsonPayload(reply string) string {
+	payload := strings.TrimSpace(reply)
+	if strings.HasPrefix(payload, "```") && strings.HasSuffix(payload, "```") {
+		payload = strings.TrimSuffix(payload, "```")
+		if newline := strings.IndexByte(payload, '\n'); newline >= 0 {
+			payload = payload[newline+1:]
+		}
+	}
+	return strings.TrimSpace(payload)
+}
+
+// checkJSONReply reports why a reply is not valid JSON for the requested format,
+// or returns an empty string when it is
+func checkJSONReply(reply string, format *openai.ChatCompletionResponseFormat) string {
+	var value any
+	if err := json.Unmarshal([]byte(jsonPayload(reply)), &value); err != nil {
+		return "not valid JSON: " + err.Error()
+	}
+	if schema := formatSchema(format); schema != nil {
+		if err := validateJSONSchema(schema, value); err != nil {
+			return "does not match the schema: " + err.Error()
+		}
+	}
+	return ""
+}
+
+// printReply prints an assistant reply after its reasoning, pretty-printing valid
+// JSON replies
+func printReply(v *gocui.View, message string, meta MessageMeta) {
+	printReasoning(v, meta.Reasoning)
+	if meta.Format == "json" && meta.JSONError == "" {
+		var indented bytes.Buffer
+		if json.Indent(&indented, []byte(jsonPayload(message)), "", "  ") == nil {
+			printJSONResponse(v, indented.String())
+			return
+		}
+	}
+	printAIResponse(v, message, replyModel(meta))
+}
+
+// replyModel returns the model that wrote a reply, falling back to the
+// conversation's model for replies saved without one
+func replyModel(meta MessageMeta) string {
+	if meta.Model != "" {
+		return meta.Model
+	}
+	return currentConvo.Model
+}
+
+// printJSONResponse prints pretty-printed JSON with syntax highlighting, keeping
+// its indentation instead of word wrapping it
+func printJSONResponse(v *gocui.View, text string) {
+	fmt.Fprintln(v)
+	for _, line := range strings.Split(highlightCode(text, "json"), "\n") {
+		fmt.Fprintf(v, "  %s\033[0m\n", line)
+	}
+	v.Autoscroll = true
+}
+
+// highlightCode colours code for the terminal, returning it unchanged if that fails
+func highlightCode(code, lang string) string {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		return code
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return code
+	}
+
+	var highlighted bytes.Buffer
+	if err := formatters.Get("terminal").Format(&highlighted, styles.Get("monokai"), iterator); err != nil {
+		return code
+	}
+	return strings.TrimRight(highlighted.String(), "\n")
+}
@@ -0,0 +1,103 @@
+// Command fakemcp is a minimal stdio MCP server for trying out atlas's MCP
+// support without a real server. It offers an "echo" tool that returns its
+// input and an "add" tool that sums two numbers.
+//
+//	mcp_servers:
+//	  fake:
+//	    command: "go"
+//	    args: ["run", "./testdata/fakemcp"]
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+type request struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"params"`
+}
+
+var tools = []map[string]any{
+	{
+		"name":        "echo",
+		"description": "Return the given text unchanged",
+		"inputSchema": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"text": map[string]any{"type": "string"}},
+			"required":   []string{"text"},
+		},
+		"annotations": map[string]any{"readOnlyHint": true},
+	},
+	{
+		"name":        "add",
+		"description": "Add two numbers",
+		"inputSchema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"a": map[string]any{"type": "number"},
+				"b": map[string]any{"type": "number"},
+			},
+			"required": []string{"a", "b"},
+		},
+	},
+}
+
+func main() {
+	scanner := bufio.NewScanner(os.Stdin)
+	encoder := json.NewEncoder(os.Stdout)
+
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || req.ID == nil {
+			// Notifications get no response
+			continue
+		}
+
+		response := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "initialize":
+			response["result"] = map[string]any{
+				"protocolVersion": "2024-11-05",
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+				"serverInfo":      map[string]any{"name": "fakemcp", "version": "0.1"},
+			}
+		case "tools/list":
+			response["result"] = map[string]any{"tools": tools}
+		case "tools/call":
+			response["result"] = callTool(req.Params.Name, req.Params.Arguments)
+		default:
+			response["error"] = map[string]any{"code": -32601, "message": "method not found: " + req.Method}
+		}
+		encoder.Encode(response)
+	}
+}
+
+func callTool(name string, arguments json.RawMessage) map[string]any {
+	var args struct {
+		Text string  `json:"text"`
+		A    float64 `json:"a"`
+		B    float64 `json:"b"`
+	}
+	json.Unmarshal(arguments, &args)
+
+	var text string
+	switch name {
+	case "echo":
+		text = args.Text
+	case "add":
+		text = fmt.Sprint(args.A + args.B)
+	default:
+		return map[string]any{
+			"content": []map[string]any{{"type": "text", "text": "unknown tool " + name}},
+			"isError": true,
+		}
+	}
+	return map[string]any{"content": []map[string]any{{"type": "text", "text": text}}}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jroimartin/gocui"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	// Longest tool output sent back to the model
+	maxToolOutput = 16000
+	// Most tool call rounds a single reply may take
+	maxToolRounds = 10
+	// How long run_shell waits for a command
+	shellTimeout = 60 * time.Second
+	// Most matching lines grep returns
+	maxGrepMatches = 200
+)
+
+// tool is a function the model can call
+type tool struct {
+	definition openai.FunctionDefinition
+	// Tools with side effects only run after the user confirms the call
+	sideEffects bool
+	run         func(ctx context.Context, arguments string) (string, error)
+}
+
+// tools maps tool names to their implementations
+var tools = map[string]tool{}
+
+// registerTool makes a tool available to models under its function name
+func registerTool(t tool) {
+	tools[t.definition.Name] = t
+}
+
+func init() {
+	registerTool(tool{
+		definition: openai.FunctionDefinition{
+			Name:        "read_file",
+			Description: "Read a text file under the working directory",
+			Parameters:  toolParameters(map[string]string{"path": "Path of the file to read"}, "path"),
+		},
+		run: readFileTool,
+	})
+	registerTool(tool{
+		definition: openai.FunctionDefinition{
+			Name:        "list_dir",
+			Description: "List the entries of a directory under the working directory",
+			Parameters:  toolParameters(map[string]string{"path": "Directory to list, defaults to the working directory"}),
+		},
+		run: listDirTool,
+	})
+	registerTool(tool{
+		definition: openai.FunctionDefinition{
+			Name:        "grep",
+			Description: "Search files under a directory of the working directory for lines matching a regular expression",
+			Parameters: toolParameters(map[string]string{
+				"pattern": "Regular expression to search for",
+				"path":    "File or directory to search, defaults to the working directory",
+			}, "pattern"),
+		},
+		run: grepTool,
+	})
+	registerTool(tool{
+		definition: openai.FunctionDefinition{
+			Name:        "run_shell",
+			Description: "Run a shell command on the local machine and return its combined output",
+			Parameters:  toolParameters(map[string]string{"command": "Command line to run with sh -c"}, "command"),
+		},
+		sideEffects: true,
+		run:         runShellTool,
+	})
+}
+
+// toolParameters builds a JSON schema for an object of string parameters
+func toolParameters(properties map[string]string, required ...string) map[string]any {
+	schema := map[string]any{}
+	for name, description := range properties {
+		schema[name] = map[string]any{"type": "string", "description": description}
+	}
+	if required == nil {
+		required = []string{}
+	}
+	return map[string]any{"type": "object", "properties": schema, "required": required}
+}
+
+// lookupTool finds a built-in tool or a tool of an enabled MCP server by name
+func lookupTool(name string) (tool, bool) {
+	if t, exists := tools[name]; exists {
+		return t, true
+	}
+	return findMCPTool(name)
+}
+
+// toolDefinitions returns the request tools for the given tool names, skipping unknown ones
+func toolDefinitions(names []string) []openai.Tool {
+	var definitions []openai.Tool
+	for _, name := range names {
+		t, exists := tools[name]
+		if !exists {
+			continue
+		}
+		definition := t.definition
+		definitions = append(definitions, openai.Tool{Type: openai.ToolTypeFunction, Function: &definition})
+	}
+	return definitions
+}
+
+// requestTools returns the definitions of the model's tools, and of the running
+// MCP servers' tools if the model opts in to them
+func (m ModelConfig) requestTools() []openai.Tool {
+	definitions := toolDefinitions(m.Tools)
+	if m.MCP {
+		definitions = append(definitions, mcpToolDefinitions()...)
+	}
+	return definitions
+}
+
+// mergeToolCalls adds streamed tool call fragments to the calls received so far.
+// Fragments with the same index belong to the same call; their arguments are
+// concatenated.
+func mergeToolCalls(calls []openai.ToolCall, fragments []openai.ToolCall) []openai.ToolCall {
+	for _, fragment := range fragments {
+		index := len(calls)
+		if fragment.Index != nil {
+			index = *fragment.Index
+		}
+
+		position := -1
+		for i, call := range calls {
+			if call.Index != nil && *call.Index == index {
+				position = i
+				break
+			}
+		}
+		if position < 0 {
+			calls = append(calls, openai.ToolCall{Index: &index, Type: openai.ToolTypeFunction})
+			position = len(calls) - 1
+		}
+
+		call := &calls[position]
+		if fragment.ID != "" {
+			call.ID = fragment.ID
+		}
+		if fragment.Function.Name != "" {
+			call.Function.Name = fragment.Function.Name
+		}
+		call.Function.Arguments += fragment.Function.Arguments
+	}
+	return calls
+}
+
+// runTool executes a tool call and returns the text sent back to the model.
+// Failures are reported to the model as the result rather than ending the reply.
+func runTool(ctx context.Context, call openai.ToolCall) string {
+	t, exists := lookupTool(call.Function.Name)
+	if !exists {
+		return fmt.Sprintf("Error: unknown tool %q", call.Function.Name)
+	}
+
+	output, err := t.run(ctx, call.Function.Arguments)
+	if err != nil {
+		output = "Error: " + err.Error()
+	}
+	if len(output) > maxToolOutput {
+		// Cut on a rune boundary so the output stays valid UTF-8
+		cut := maxToolOutput
+		for cut > 0 && !utf8.RuneStart(output[cut]) {
+			cut--
+		}
+		output = output[:cut] + "\n[output truncated]"
+	}
+	return output
+}
+
+// checkWorkingDirPath makes sure a path given to a tool is inside the working
+// directory once symlinks are followed, so the model can't read files such as
+// the config's API keys or ~/.ssh without the user running the command
+func checkWorkingDirPath(path string) error {
+	root, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return fmt.Errorf("failed to resolve working directory: %w", err)
+	}
+	resolved, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+	if resolved, err = filepath.EvalSymlinks(resolved); err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside the working directory", path)
+	}
+	return nil
+}
+
+// toolArguments decodes a tool call's JSON arguments
+func toolArguments(arguments string, into any) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if err := json.Unmarshal([]byte(arguments), into); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func readFileTool(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := toolArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	if err := checkWorkingDirPath(args.Path); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(args.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return string(data), nil
+}
+
+func listDirTool(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := toolArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+	if err := checkWorkingDirPath(args.Path); err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(args.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "\n"), nil
+}
+
+func grepTool(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+	}
+	if err := toolArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+	if err := checkWorkingDirPath(args.Path); err != nil {
+		return "", err
+	}
+
+	pattern, err := regexp.Compile(args.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	var matches []string
+	err = filepath.WalkDir(args.Path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() {
+			if path != args.Path && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Symlinks may point outside the working directory
+		if entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for line := 1; scanner.Scan(); line++ {
+			if pattern.Match(scanner.Bytes()) {
+				matches = append(matches, fmt.Sprintf("%s:%d:%s", path, line, scanner.Text()))
+				if len(matches) >= maxGrepMatches {
+					return fs.SkipAll
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return "No matches", nil
+	}
+	return strings.Join(matches, "\n"), nil
+}
+
+func runShellTool(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := toolArguments(arguments, &args); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, shellTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "sh", "-c", args.Command).CombinedOutput()
+	if err != nil {
+		return fmt.Sprintf("%s\n[%v]", output, err), nil
+	}
+	return string(output), nil
+}
+
+// runToolCalls runs the tool calls of a reply and returns the tool result messages.
+// Calls to tools with side effects are confirmed by the user first. It only fails
+// when the request is cancelled.
+func runToolCalls(ctx context.Context, g *gocui.Gui, calls []openai.ToolCall) ([]openai.ChatCompletionMessage, error) {
+	var results []openai.ChatCompletionMessage
+	for _, call := range calls {
+		var output string
+		t, exists := lookupTool(call.Function.Name)
+		if exists && t.sideEffects {
+			question := fmt.Sprintf("The model wants to call %s with:\n\n%s\n\nAllow it?", call.Function.Name, call.Function.Arguments)
+			allowed, err := confirmAction(ctx, g, question)
+			if err != nil {
+				return results, err
+			}
+			if !allowed {
+				output = "The user declined this tool call."
+			}
+		}
+
+		if output == "" {
+			name := call.Function.Name
+			updateUI(g, func(g *gocui.Gui) error {
+				setStatus(g, "Running "+name+"... (Esc to cancel)")
+				return nil
+			})
+			output = runTool(ctx, call)
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+		}
+
+		results = append(results, openai.ChatCompletionMessage{
+			Role:       openai.ChatMessageRoleTool,
+			Content:    output,
+			Name:       call.Function.Name,
+			ToolCallID: call.ID,
+		})
+	}
+	return results, nil
+}
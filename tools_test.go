@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// chdirToolsTest makes a temporary working directory with a file, and a secret
+// outside it that a symlink inside points to
+func chdirToolsTest(t *testing.T) (secret string) {
+	t.Helper()
+	root, outside := t.TempDir(), t.TempDir()
+	secret = filepath.Join(outside, "config.yml")
+	files := map[string]string{
+		filepath.Join(root, "notes.txt"):      "todo: ship it\n",
+		filepath.Join(root, "src", "main.go"): "package main // todo\n",
+		secret:                                "api_key: todo-secret\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(secret, filepath.Join(root, "link.yml")); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return secret
+}
+
+func TestFileToolsStayInWorkingDirectory(t *testing.T) {
+	secret := chdirToolsTest(t)
+
+	for _, test := range []struct {
+		tool, arguments string
+		want            string // part of the result
+	}{
+		{"read_file", `{"path": "notes.txt"}`, "todo: ship it"},
+		{"read_file", `{"path": "src/../notes.txt"}`, "todo: ship it"},
+		{"read_file", `{"path": "` + secret + `"}`, "outside the working directory"},
+		{"read_file", `{"path": "../` + filepath.Base(filepath.Dir(secret)) + `/config.yml"}`, "outside the working directory"},
+		{"read_file", `{"path": "link.yml"}`, "outside the working directory"},
+		{"list_dir", `{}`, "notes.txt\nsrc/"},
+		{"list_dir", `{"path": "/"}`, "outside the working directory"},
+		{"grep", `{"pattern": "todo"}`, "notes.txt:1:todo: ship it"},
+		{"grep", `{"pattern": "todo", "path": "/"}`, "outside the working directory"},
+	} {
+		got := runTool(context.Background(), openai.ToolCall{Function: openai.FunctionCall{Name: test.tool, Arguments: test.arguments}})
+		if !strings.Contains(got, test.want) {
+			t.Errorf("%s %s = %q, want %q", test.tool, test.arguments, got, test.want)
+		}
+		if strings.Contains(got, "todo-secret") {
+			t.Errorf("%s %s read the file outside the working directory", test.tool, test.arguments)
+		}
+	}
+}
+
+func TestRunToolTruncatesOnRuneBoundary(t *testing.T) {
+	chdirToolsTest(t)
+	if err := os.WriteFile("big.txt", []byte("a"+strings.Repeat("é", maxToolOutput)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got := runTool(context.Background(), openai.ToolCall{Function: openai.FunctionCall{Name: "read_file", Arguments: `{"path": "big.txt"}`}})
+	if !strings.HasSuffix(got, "\n[output truncated]") || len(got) > maxToolOutput+len("\n[output truncated]") {
+		t.Fatalf("output of %d bytes, want it truncated to %d", len(got), maxToolOutput)
+	}
+	if !utf8.ValidString(got) {
+		t.Error("truncated output is not valid UTF-8")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ModelPricing is a model's price in dollars per million tokens
+type ModelPricing struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// cost returns the dollar cost of the given token counts
+func (p *ModelPricing) cost(promptTokens, completionTokens int) float64 {
+	if p == nil {
+		return 0
+	}
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1_000_000
+}
+
+// ConvoUsage is the running token and cost total of a conversation
+type ConvoUsage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// add adds a message's usage to the total
+func (u *ConvoUsage) add(meta MessageMeta) {
+	u.PromptTokens += meta.PromptTokens
+	u.CompletionTokens += meta.CompletionTokens
+	u.Cost += meta.Cost
+}
+
+// UsageRecord is one line of the usage ledger
+type UsageRecord struct {
+	Time             time.Time `json:"time"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	Conversation     string    `json:"conversation"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"estimated,omitempty"`
+	Cost             float64   `json:"cost"`
+	LatencyMs        int64     `json:"latency_ms"`
+}
+
+// GetUsageLedgerPath returns the path of the usage ledger file
+func GetUsageLedgerPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	return filepath.Join(homeDir, ".config", "atlas", "usage.jsonl"), nil
+}
+
+// RecordUsage appends a record to the usage ledger
+func RecordUsage(record UsageRecord) error {
+	ledgerPath, err := GetUsageLedgerPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ledgerPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage record: %w", err)
+	}
+
+	file, err := os.OpenFile(ledgerPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage record: %w", err)
+	}
+	return nil
+}
+
+// LoadUsage reads every record from the usage ledger
+func LoadUsage() ([]UsageRecord, error) {
+	ledgerPath, err := GetUsageLedgerPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(ledgerPath)
+	if os.IsNotExist(err) {
+		return []UsageRecord{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Skip damaged lines but keep the rest of the ledger usable
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	return records, nil
+}
+
+// usageGroupKey returns the key a record is grouped under for the given grouping
+func usageGroupKey(record UsageRecord, groupBy string) (string, error) {
+	switch groupBy {
+	case "provider":
+		return record.Provider, nil
+	case "model":
+		return record.Provider + "/" + record.Model, nil
+	case "day":
+		return record.Time.Local().Format("2006-01-02"), nil
+	}
+	return "", fmt.Errorf("unknown grouping %q (use provider, model or day)", groupBy)
+}
+
+// runUsageCommand prints usage totals grouped by provider, model or day
+func runUsageCommand(args []string, out io.Writer) error {
+	groupBy := "model"
+	if len(args) > 0 {
+		groupBy = args[0]
+	}
+
+	records, err := LoadUsage()
+	if err != nil {
+		return err
+	}
+
+	totals := map[string]*ConvoUsage{}
+	requests := map[string]int{}
+	for _, record := range records {
+		key, err := usageGroupKey(record, groupBy)
+		if err != nil {
+			return err
+		}
+		if totals[key] == nil {
+			totals[key] = &ConvoUsage{}
+		}
+		totals[key].PromptTokens += record.PromptTokens
+		totals[key].CompletionTokens += record.CompletionTokens
+		totals[key].Cost += record.Cost
+		requests[key]++
+	}
+
+	keys := make([]string, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tREQUESTS\tPROMPT\tCOMPLETION\tCOST\n", strings.ToUpper(groupBy))
+	for _, key := range keys {
+		total := totals[key]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t$%.4f\n", key, requests[key], total.PromptTokens, total.CompletionTokens, total.Cost)
+	}
+	return w.Flush()
+}
+
+// formatUsage renders token counts and cost for the UI
+func formatUsage(promptTokens, completionTokens int, cost float64) string {
+	text := fmt.Sprintf("%d → %d tokens", promptTokens, completionTokens)
+	if cost > 0 {
+		text += fmt.Sprintf(" · $%.4f", cost)
+	}
+	return text
+}